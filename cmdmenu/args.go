@@ -0,0 +1,306 @@
+package cmdmenu
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// converts a single argument. returned errors are shown to the user
+type Type struct {
+	Name  string
+	Parse func(value string) (any, error)
+}
+
+var (
+	String = &Type{
+		Name: "string",
+		Parse: func(value string) (any, error) {
+			return value, nil
+		},
+	}
+	Int = &Type{
+		Name: "int",
+		Parse: func(value string) (any, error) {
+			return strconv.Atoi(value)
+		},
+	}
+	Duration = &Type{
+		Name: "duration",
+		Parse: func(value string) (any, error) {
+			return time.ParseDuration(value)
+		},
+	}
+	URL = &Type{
+		Name: "url",
+		Parse: func(value string) (any, error) {
+			u, err := url.Parse(value)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+				u.Host == "" {
+				return nil, errors.New("should be an http or https url")
+			}
+			return u, nil
+		},
+	}
+)
+
+type Param struct {
+	Name string
+	// defaults to String
+	Type     *Type
+	Optional bool
+	// takes the rest of the arguments. must be last.
+	// strings get the rest of the line as typed
+	Variadic bool
+}
+
+// -name for booleans or -name=value if Type is set
+type Flag struct {
+	Name string
+	Type *Type
+}
+
+type Args struct {
+	values  map[string]any
+	raw     map[string]string
+	strings map[string][]string
+}
+
+func (a Args) Has(name string) bool {
+	_, ok := a.values[name]
+	return ok
+}
+
+func (a Args) Value(name string) any {
+	return a.values[name]
+}
+
+// as typed for anything that isnt a string
+func (a Args) String(name string) string {
+	value, ok := a.values[name].(string)
+	if ok {
+		return value
+	}
+	return a.raw[name]
+}
+
+// each argument of a variadic param
+func (a Args) Strings(name string) []string {
+	return a.strings[name]
+}
+
+// each parsed value of a variadic param with a type
+func (a Args) Values(name string) []any {
+	values, _ := a.values[name].([]any)
+	return values
+}
+
+func (a Args) Int(name string) int {
+	value, _ := a.values[name].(int)
+	return value
+}
+
+func (a Args) Duration(name string) time.Duration {
+	value, _ := a.values[name].(time.Duration)
+	return value
+}
+
+func (a Args) URL(name string) *url.URL {
+	value, _ := a.values[name].(*url.URL)
+	return value
+}
+
+// also for params that parse to a bool
+func (a Args) Flag(name string) bool {
+	value, _ := a.values[name].(bool)
+	return value
+}
+
+type token struct {
+	value  string
+	start  int
+	quoted bool
+}
+
+// splits on spaces. double quotes group words and \" escapes inside them.
+// quotes only count at the start of a word so apostrophes are left alone
+func tokenize(line string) []token {
+	var tokens []token
+	i := 0
+	for i < len(line) {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+
+		start := i
+
+		if line[i] == '"' {
+			value := strings.Builder{}
+			i++
+			for i < len(line) && line[i] != '"' {
+				if line[i] == '\\' && i+1 < len(line) && line[i+1] == '"' {
+					i++
+				}
+				value.WriteByte(line[i])
+				i++
+			}
+			i++ // closing quote, if any
+			tokens = append(tokens, token{
+				value: value.String(), start: start, quoted: true,
+			})
+			continue
+		}
+
+		for i < len(line) && line[i] != ' ' {
+			i++
+		}
+		tokens = append(tokens, token{value: line[start:i], start: start})
+	}
+	return tokens
+}
+
+func typeOrString(t *Type) *Type {
+	if t == nil {
+		return String
+	}
+	return t
+}
+
+func (c *Command[T]) findFlag(value string) (*Flag, string, bool) {
+	name, flagValue, hasValue := strings.Cut(strings.TrimPrefix(value, "-"), "=")
+	for i := range c.Flags {
+		flag := &c.Flags[i]
+		if !strings.EqualFold(flag.Name, name) {
+			continue
+		}
+		// booleans dont take a value and others need one
+		if hasValue != (flag.Type != nil) {
+			return nil, "", false
+		}
+		return flag, flagValue, true
+	}
+	return nil, "", false
+}
+
+func parseValue(name string, t *Type, value string) (any, error) {
+	parsed, err := typeOrString(t).Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", name, err.Error())
+	}
+	return parsed, nil
+}
+
+func (c *Command[T]) parseArgs(args []string) (Args, error) {
+	parsed := Args{
+		values:  map[string]any{},
+		raw:     map[string]string{},
+		strings: map[string][]string{},
+	}
+
+	line := strings.Join(args, " ")
+	tokens := tokenize(line)
+
+	param := 0
+	flagsDone := false
+
+	for i, t := range tokens {
+		if !t.quoted && !flagsDone && strings.HasPrefix(t.value, "-") {
+			if t.value == "--" {
+				flagsDone = true
+				continue
+			}
+			flag, value, ok := c.findFlag(t.value)
+			if ok {
+				if flag.Type == nil {
+					parsed.values[flag.Name] = true
+					continue
+				}
+				v, err := parseValue("-"+flag.Name, flag.Type, value)
+				if err != nil {
+					return parsed, err
+				}
+				parsed.values[flag.Name] = v
+				parsed.raw[flag.Name] = value
+				continue
+			}
+		}
+
+		if param >= len(c.Params) {
+			return parsed, errors.New("unexpected argument: " + t.value)
+		}
+		p := c.Params[param]
+
+		if !p.Variadic {
+			v, err := parseValue(p.Name, p.Type, t.value)
+			if err != nil {
+				return parsed, err
+			}
+			parsed.values[p.Name] = v
+			parsed.raw[p.Name] = t.value
+			param++
+			continue
+		}
+
+		// rest of the line as typed
+		if typeOrString(p.Type) == String {
+			rest := line[t.start:]
+			if i == len(tokens)-1 && t.quoted {
+				rest = t.value
+			}
+			parsed.values[p.Name] = rest
+			parsed.raw[p.Name] = rest
+			for _, t := range tokens[i:] {
+				parsed.strings[p.Name] = append(parsed.strings[p.Name], t.value)
+			}
+			break
+		}
+
+		v, err := parseValue(p.Name, p.Type, t.value)
+		if err != nil {
+			return parsed, err
+		}
+		values, _ := parsed.values[p.Name].([]any)
+		parsed.values[p.Name] = append(values, v)
+		parsed.strings[p.Name] = append(parsed.strings[p.Name], t.value)
+		parsed.raw[p.Name] = strings.Join(parsed.strings[p.Name], " ")
+	}
+
+	for _, p := range c.Params {
+		if !p.Optional && !parsed.Has(p.Name) {
+			return parsed, errors.New("missing " + p.Name)
+		}
+	}
+
+	return parsed, nil
+}
+
+// generated from flags and params
+func (c *Command[T]) Usage() string {
+	parts := []string{}
+
+	for _, flag := range c.Flags {
+		if flag.Type == nil {
+			parts = append(parts, "[-"+flag.Name+"]")
+		} else {
+			parts = append(parts, "[-"+flag.Name+"=<"+flag.Type.Name+">]")
+		}
+	}
+
+	for _, p := range c.Params {
+		name := p.Name
+		if p.Variadic {
+			name += "..."
+		}
+		if p.Optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "<"+name+">")
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,97 @@
+package cmdmenu
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens := tokenize(`one  "two three" it's "say \"hi\""`)
+
+	values := []string{}
+	for _, t := range tokens {
+		values = append(values, t.value)
+	}
+
+	expected := []string{"one", "two three", "it's", `say "hi"`}
+	if !slices.Equal(values, expected) {
+		t.Fatalf("expected %q, got %q", expected, values)
+	}
+}
+
+var testCommand = Command[struct{}]{
+	Name: "test",
+	Params: []Param{
+		{Name: "name"},
+		{Name: "count", Type: Int, Optional: true},
+		{Name: "reason", Optional: true, Variadic: true},
+	},
+	Flags: []Flag{
+		{Name: "quiet"},
+		{Name: "for", Type: Duration},
+	},
+}
+
+func TestParseArgs(t *testing.T) {
+	args, err := testCommand.parseArgs(strings.Fields(
+		"-quiet -for=1h alice 3 some  -reason here",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !args.Flag("quiet") || args.Duration("for") != time.Hour {
+		t.Fatalf("flags not parsed: %+v", args)
+	}
+	if args.String("name") != "alice" || args.Int("count") != 3 {
+		t.Fatalf("params not parsed: %+v", args)
+	}
+	if args.String("reason") != "some -reason here" {
+		t.Fatalf("unexpected reason: %q", args.String("reason"))
+	}
+
+	args, err = testCommand.parseArgs([]string{"--", "-quiet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.Flag("quiet") || args.String("name") != "-quiet" {
+		t.Fatalf("flag parsed after --: %+v", args)
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	tests := map[string]string{
+		"":                     "missing name",
+		"alice nope":           "invalid count: ",
+		"-for=soon alice":      "invalid -for: ",
+		`"quoted name" 1 "ok"`: "",
+	}
+
+	for line, expected := range tests {
+		_, err := testCommand.parseArgs(strings.Fields(line))
+		if expected == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error: %s", line, err)
+			}
+			continue
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("%q: expected %q, got %v", line, expected, err)
+		}
+	}
+
+	command := Command[struct{}]{Params: []Param{{Name: "only"}}}
+	_, err := command.parseArgs([]string{"one", "two"})
+	if err == nil || err.Error() != "unexpected argument: two" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	expected := "[-quiet] [-for=<duration>] <name> [count] [reason...]"
+	if testCommand.Usage() != expected {
+		t.Fatalf("expected %q, got %q", expected, testCommand.Usage())
+	}
+}
@@ -1,26 +1,73 @@
 package cmdmenu
 
+import (
+	"context"
+	"strings"
+)
+
 type Command[T any] struct {
-	Name   string
-	Args   int
-	Usage  string
-	Handle func(user *T, args []string)
+	Name        string
+	Description string
+	Examples    []string
+	// has to be typed in full, for dangerous commands
+	Exact  bool
+	Params []Param
+	Flags  []Flag
+	// for help when the handler runs another menu with the rest of the args
+	Submenu HelpSource
+	Handle  func(ctx context.Context, user *T, args Args)
+}
+
+type onHandleKey struct{}
+
+// fn is called right before a handler runs, so callers can tell a real
+// run from a usage error
+func OnHandle(ctx context.Context, fn func()) context.Context {
+	return context.WithValue(ctx, onHandleKey{}, fn)
 }
 
 func (c *Command[T]) getName() string {
 	return c.Name
 }
 
+func (c *Command[T]) isExact() bool {
+	return c.Exact
+}
+
 func (c *Command[T]) Run(
-	args []string, userValue *T,
+	ctx context.Context, args []string, userValue *T,
 	printUsage func(msg string),
 	parents ...Runnable[T],
 ) {
-	if c.Args == 0 || len(args) >= c.Args {
-		c.Handle(userValue, args)
+	parsed, err := c.parseArgs(args)
+	if err == nil {
+		if onHandle, ok := ctx.Value(onHandleKey{}).(func()); ok {
+			onHandle()
+		}
+		c.Handle(ctx, userValue, parsed)
 		return
 	}
-	printUsage(
-		getCallStack(c.Name, parents) + " " + c.Usage,
-	)
+
+	usage := strings.TrimSpace(getCallStack(c.Name, parents) + " " + c.Usage())
+
+	// no args at all is just asking how to use it
+	if len(args) == 0 {
+		printUsage(usage)
+		return
+	}
+
+	printUsage(usage + "\n  " + err.Error())
+}
+
+func (c *Command[T]) Help() HelpEntry {
+	entry := HelpEntry{
+		Name:        c.Name,
+		Usage:       c.Usage(),
+		Description: c.Description,
+		Examples:    c.Examples,
+	}
+	if c.Submenu != nil {
+		entry.Children = c.Submenu.Help().Children
+	}
+	return entry
 }
@@ -0,0 +1,73 @@
+package cmdmenu
+
+import (
+	"strings"
+)
+
+// describes a menu or command and everything under it.
+// not generic so menus of different types can be nested for help
+type HelpEntry struct {
+	Name        string
+	Usage       string
+	Description string
+	// without a prefix, e.g. "server add libera irc.libera.chat:6697"
+	Examples []string
+	Children []HelpEntry
+}
+
+type HelpSource interface {
+	Help() HelpEntry
+}
+
+// follows names down the tree. returns the call stack that was found
+func (e HelpEntry) Find(path []string) (HelpEntry, string, bool) {
+	callStack := e.Name
+	for _, name := range path {
+		name = strings.ToLower(name)
+		found := false
+		for _, child := range e.Children {
+			if child.Name == name {
+				e = child
+				callStack += " " + name
+				found = true
+				break
+			}
+		}
+		if !found {
+			return e, callStack, false
+		}
+	}
+	return e, callStack, true
+}
+
+func (e HelpEntry) line(callStack string) string {
+	out := strings.TrimSpace(callStack + " " + e.Usage)
+	if e.Description != "" {
+		out += ": " + e.Description
+	}
+	return out
+}
+
+func (e HelpEntry) writeChildren(out *strings.Builder, indent string) {
+	for _, child := range e.Children {
+		out.WriteString("\n" + indent + child.line(child.Name))
+		child.writeChildren(out, indent+"  ")
+	}
+}
+
+// tree of usage and descriptions with examples at the end.
+// prefix is added to examples
+func (e HelpEntry) Format(callStack string, prefix string) string {
+	out := strings.Builder{}
+	out.WriteString(e.line(callStack))
+	e.writeChildren(&out, "  ")
+
+	if len(e.Examples) > 0 {
+		out.WriteString("\nexamples:")
+		for _, example := range e.Examples {
+			out.WriteString("\n  " + prefix + example)
+		}
+	}
+
+	return out.String()
+}
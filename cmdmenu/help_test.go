@@ -0,0 +1,54 @@
+package cmdmenu
+
+import (
+	"testing"
+)
+
+var testMenu = Menu[struct{}]{
+	Name:     "server",
+	Examples: []string{"server add libera irc.libera.chat:6697"},
+	Commands: []Runnable[struct{}]{
+		&Command[struct{}]{
+			Name:        "add",
+			Description: "add a server",
+			Params:      []Param{{Name: "name"}, {Name: "address"}},
+		},
+		&Menu[struct{}]{
+			Name:        "set",
+			Description: "change things",
+			Commands: []Runnable[struct{}]{
+				&Command[struct{}]{
+					Name:        "nick",
+					Description: "set the nick",
+					Params:      []Param{{Name: "nick"}},
+				},
+			},
+		},
+	},
+}
+
+func TestHelpFormat(t *testing.T) {
+	expected := "server\n" +
+		"  add <name> <address>: add a server\n" +
+		"  set: change things\n" +
+		"    nick <nick>: set the nick\n" +
+		"examples:\n" +
+		"  m!server add libera irc.libera.chat:6697"
+
+	out := testMenu.Help().Format("server", "m!")
+	if out != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestHelpFind(t *testing.T) {
+	entry, callStack, ok := testMenu.Help().Find([]string{"SET", "nick"})
+	if !ok || callStack != "server set nick" || entry.Usage != "<nick>" {
+		t.Fatalf("unexpected entry: %+v %q %v", entry, callStack, ok)
+	}
+
+	_, callStack, ok = testMenu.Help().Find([]string{"set", "nope"})
+	if ok || callStack != "server set" {
+		t.Fatalf("expected to stop at server set, got %q %v", callStack, ok)
+	}
+}
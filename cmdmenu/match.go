@@ -0,0 +1,103 @@
+package cmdmenu
+
+import (
+	"slices"
+	"strings"
+)
+
+// anything further away isnt worth suggesting
+const MAX_SUGGESTION_DISTANCE = 2
+
+func levenshtein(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+// finds name in names, or the only name it's a prefix of that canPrefix allows.
+// nil canPrefix allows all. returns -1 and the closest names if nothing matched
+func Match(
+	name string, names []string, canPrefix func(name string) bool,
+) (int, []string) {
+	name = strings.ToLower(name)
+
+	i := slices.Index(names, name)
+	if i > -1 {
+		return i, nil
+	}
+
+	if name != "" {
+		found := -1
+		for i := range names {
+			if !strings.HasPrefix(names[i], name) {
+				continue
+			}
+			if canPrefix != nil && !canPrefix(names[i]) {
+				continue
+			}
+			if found > -1 {
+				// ambiguous
+				found = -1
+				break
+			}
+			found = i
+		}
+		if found > -1 {
+			return found, nil
+		}
+	}
+
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	suggestions := []suggestion{}
+
+	for _, other := range names {
+		// typed the start of it so its most likely what they meant
+		if len(name) > 1 && strings.HasPrefix(other, name) {
+			suggestions = append(suggestions, suggestion{other, 0})
+			continue
+		}
+		distance := levenshtein(name, other)
+		// short names are only a few edits away from everything
+		if distance <= min(MAX_SUGGESTION_DISTANCE, len(name)/2) {
+			suggestions = append(suggestions, suggestion{other, distance})
+		}
+	}
+
+	slices.SortStableFunc(suggestions, func(a, b suggestion) int {
+		return a.distance - b.distance
+	})
+
+	out := make([]string, len(suggestions))
+	for i := range suggestions {
+		out[i] = suggestions[i].name
+	}
+	return -1, out
+}
+
+// at most three. e.g. "did you mean server set addr or server set nick?"
+func DidYouMean(prefix string, suggestions []string) string {
+	out := []string{}
+	for _, suggestion := range suggestions[:min(len(suggestions), 3)] {
+		out = append(out, prefix+suggestion)
+	}
+	return "did you mean " + strings.Join(out, " or ") + "?"
+}
@@ -0,0 +1,63 @@
+package cmdmenu
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "abc", 3},
+		{"set", "set", 0},
+		{"addr", "adr", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, test := range tests {
+		distance := levenshtein(test.a, test.b)
+		if distance != test.distance {
+			t.Errorf("%q %q: expected %d, got %d",
+				test.a, test.b, test.distance, distance)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	names := []string{"list", "stats", "set", "remove"}
+	notRemove := func(name string) bool { return name != "remove" }
+
+	i, _ := Match("LIST", names, nil)
+	if i != 0 {
+		t.Fatalf("expected exact match, got %d", i)
+	}
+
+	i, _ = Match("li", names, nil)
+	if i != 0 {
+		t.Fatalf("expected prefix match, got %d", i)
+	}
+
+	// ambiguous
+	i, suggestions := Match("s", names, nil)
+	if i != -1 || len(suggestions) != 0 {
+		t.Fatalf("expected no match, got %d %v", i, suggestions)
+	}
+
+	i, suggestions = Match("rem", names, notRemove)
+	if i != -1 || !slices.Equal(suggestions, []string{"remove"}) {
+		t.Fatalf("expected suggestion only, got %d %v", i, suggestions)
+	}
+
+	i, suggestions = Match("sat", names, nil)
+	if i != -1 || !slices.Equal(suggestions, []string{"set"}) {
+		t.Fatalf("unexpected suggestions: %d %v", i, suggestions)
+	}
+}
+
+func TestDidYouMean(t *testing.T) {
+	out := DidYouMean("server ", []string{"set", "stats"})
+	if out != "did you mean server set or server stats?" {
+		t.Fatalf("unexpected: %q", out)
+	}
+}
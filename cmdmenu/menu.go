@@ -1,11 +1,17 @@
 package cmdmenu
 
 import (
+	"context"
+	"slices"
 	"strings"
 )
 
 type Menu[T any] struct {
-	Name     string
+	Name        string
+	Description string
+	Examples    []string
+	// dont resolve prefixes for any commands in this menu
+	Exact    bool
 	Commands []Runnable[T]
 }
 
@@ -13,30 +19,72 @@ func (m *Menu[T]) getName() string {
 	return m.Name
 }
 
+func (m *Menu[T]) isExact() bool {
+	return false
+}
+
+func (m *Menu[T]) names() []string {
+	names := make([]string, len(m.Commands))
+	for i := range m.Commands {
+		names[i] = m.Commands[i].getName()
+	}
+	return names
+}
+
+func (m *Menu[T]) match(name string, names []string) (int, []string) {
+	return Match(name, names, func(name string) bool {
+		return !m.Exact && !m.Commands[slices.Index(names, name)].isExact()
+	})
+}
+
+// full name of the subcommand that name would run, or empty if none
+func (m *Menu[T]) Resolve(name string) string {
+	names := m.names()
+	i, _ := m.match(name, names)
+	if i == -1 {
+		return ""
+	}
+	return names[i]
+}
+
 func (m *Menu[T]) Run(
-	args []string, userValue *T,
+	ctx context.Context, args []string, userValue *T,
 	printUsage func(msg string),
 	parents ...Runnable[T],
 ) {
-	if len(args) > 0 {
-		name := strings.ToLower(args[0])
-		for i := range m.Commands {
-			if name == m.Commands[i].getName() {
-				m.Commands[i].Run(
-					args[1:], userValue, printUsage, append(parents, m)...,
-				)
-				return
-			}
-		}
+	names := m.names()
+
+	callStack := getCallStack(m.Name, parents)
+	usage := callStack + " <subcommand>\n  " + strings.Join(names, ", ")
+
+	if len(args) == 0 {
+		printUsage(usage)
+		return
 	}
 
-	names := make([]string, len(m.Commands))
-	for i := range m.Commands {
-		names[i] = m.Commands[i].getName()
+	i, suggestions := m.match(args[0], names)
+	if i > -1 {
+		m.Commands[i].Run(
+			ctx, args[1:], userValue, printUsage, append(parents, m)...,
+		)
+		return
+	}
+
+	if len(suggestions) > 0 {
+		usage += "\n  " + DidYouMean(callStack+" ", suggestions)
 	}
 
-	printUsage(
-		getCallStack(m.Name, parents) + " <subcommand>\n  " +
-			strings.Join(names, ", "),
-	)
+	printUsage(usage)
+}
+
+func (m *Menu[T]) Help() HelpEntry {
+	entry := HelpEntry{
+		Name:        m.Name,
+		Description: m.Description,
+		Examples:    m.Examples,
+	}
+	for i := range m.Commands {
+		entry.Children = append(entry.Children, m.Commands[i].Help())
+	}
+	return entry
 }
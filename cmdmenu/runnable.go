@@ -1,9 +1,13 @@
 package cmdmenu
 
+import "context"
+
 type Runnable[T any] interface {
+	HelpSource
 	getName() string
+	isExact() bool
 	Run(
-		args []string, userValue *T,
+		ctx context.Context, args []string, userValue *T,
 		printUsage func(msg string),
 		parents ...Runnable[T],
 	)
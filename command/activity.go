@@ -0,0 +1,78 @@
+package command
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/makinori/mikogo/cmdmenu"
+	"github.com/makinori/mikogo/irc"
+)
+
+// recent commands for the dashboard. only the command and subcommand
+// are kept so passwords and such dont end up in memory
+
+const activityMax = 50
+
+type Activity struct {
+	Time    time.Time
+	Server  string
+	Where   string
+	Sender  string
+	Command string
+	Allowed bool
+}
+
+var (
+	activity      = make([]Activity, 0, activityMax)
+	activityNext  = 0
+	activityMutex = sync.Mutex{}
+)
+
+// args[1] is only kept if it names a subcommand of the menu
+func activityCommand(command *Command, args []string) string {
+	menu, ok := command.Menu.(*cmdmenu.Menu[irc.Message])
+	if !ok || len(args) < 2 {
+		return command.Name
+	}
+	sub := menu.Resolve(args[1])
+	if sub == "" {
+		return command.Name
+	}
+	return command.Name + " " + sub
+}
+
+func addActivity(
+	msg *irc.Message, command *Command, args []string, allowed bool,
+) {
+	entry := Activity{
+		Time:    time.Now(),
+		Server:  msg.Client.Name,
+		Where:   msg.Where,
+		Sender:  msg.Sender,
+		Command: activityCommand(command, args),
+		Allowed: allowed,
+	}
+
+	activityMutex.Lock()
+	defer activityMutex.Unlock()
+
+	if len(activity) < activityMax {
+		activity = append(activity, entry)
+	} else {
+		activity[activityNext] = entry
+	}
+	activityNext = (activityNext + 1) % activityMax
+}
+
+// newest first
+func RecentActivity() []Activity {
+	activityMutex.Lock()
+	defer activityMutex.Unlock()
+
+	out := make([]Activity, 0, len(activity))
+	out = append(out, activity[activityNext:]...)
+	out = append(out, activity[:activityNext]...)
+	slices.Reverse(out)
+	return out
+}
@@ -1,113 +1,431 @@
 package command
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"slices"
 	"strings"
 
 	"github.com/makinori/mikogo/cmdmenu"
 	"github.com/makinori/mikogo/db"
 	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/ircf"
+	"github.com/makinori/mikogo/manage"
 )
 
-func adminChannelJoin(msg *irc.Message, args []string) {
-	channel := args[1]
-	if !strings.HasPrefix(channel, "#") {
-		channel = "#" + channel
+func adminChannelJoin(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	_, err := manage.JoinChannel(args.String("server"), args.String("channel"))
+	if err != nil {
+		msg.Reply(err.Error())
+		return
 	}
 
-	server, err, _ := db.Servers.Get(args[0])
+	msg.Ack("added channel! will join")
+}
+
+func adminChannelLeave(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	_, err := manage.LeaveChannel(args.String("server"), args.String("channel"))
 	if err != nil {
-		msg.Client.Send(msg.Where, "failed to get: "+err.Error())
+		msg.Reply(err.Error())
 		return
 	}
 
-	if slices.Contains(server.Channels, channel) {
-		msg.Client.Send(msg.Where, "already in channel")
+	msg.Ack("removed channel! will leave")
+}
+
+func adminChannelSync(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	err := manage.SyncChannels(msg.Client.Name)
+	if err != nil {
+		msg.Reply(err.Error())
 		return
 	}
 
-	server.Channels = append(server.Channels, channel)
+	msg.Ack("will resync channels")
+}
 
-	err = db.Servers.Put(args[0], server)
+type channelSettings struct {
+	msg     *irc.Message
+	server  string
+	channel string
+	// as loaded, for showing. changes go through update
+	settings db.Channel
+}
+
+// applies the change to the stored settings in one transaction so
+// concurrent edits arent lost. errors from change are replied as is
+func (s *channelSettings) update(
+	reply string, change func(settings *db.Channel) error,
+) {
+	var changeErr error
+	err := db.UpdateChannel(s.server, s.channel,
+		func(settings *db.Channel) error {
+			changeErr = change(settings)
+			return changeErr
+		},
+	)
+	if changeErr != nil {
+		s.msg.Reply(changeErr.Error())
+		return
+	}
 	if err != nil {
-		msg.Client.Send(msg.Where, "failed to put: "+err.Error())
+		s.msg.Reply("failed to update: " + err.Error())
 		return
 	}
+	s.msg.Ack(reply)
+}
+
+func channelSettingTrustBot(ctx context.Context, s *channelSettings, args cmdmenu.Args) {
+	bot := args.String("nick")
+
+	s.update("will listen to "+bot+" in "+s.channel,
+		func(settings *db.Channel) error {
+			if slices.ContainsFunc(settings.TrustedBots, func(nick string) bool {
+				return strings.EqualFold(nick, bot)
+			}) {
+				return errors.New("already trusted")
+			}
+			settings.TrustedBots = append(settings.TrustedBots, bot)
+			return nil
+		},
+	)
+}
 
-	msg.Client.Send(msg.Where, "added channel! will join")
+func channelSettingUntrustBot(ctx context.Context, s *channelSettings, args cmdmenu.Args) {
+	bot := args.String("nick")
 
-	irc.Sync()
+	s.update("will ignore "+bot+" in "+s.channel,
+		func(settings *db.Channel) error {
+			i := slices.IndexFunc(settings.TrustedBots, func(nick string) bool {
+				return strings.EqualFold(nick, bot)
+			})
+			if i == -1 {
+				return errors.New("not trusted")
+			}
+			settings.TrustedBots = slices.Delete(settings.TrustedBots, i, i+1)
+			return nil
+		},
+	)
 }
 
-func adminChannelLeave(msg *irc.Message, args []string) {
-	channel := args[1]
-	if !strings.HasPrefix(channel, "#") {
-		channel = "#" + channel
+func channelSettingPrefix(ctx context.Context, s *channelSettings, args cmdmenu.Args) {
+	prefix := args.String("prefix")
+
+	reply := "prefix in " + s.channel + " is now " + prefix
+	if prefix == "" {
+		reply = "reset prefix in " + s.channel
 	}
 
-	server, err, _ := db.Servers.Get(args[0])
-	if err != nil {
-		msg.Client.Send(msg.Where, "failed to get: "+err.Error())
+	s.update(reply, func(settings *db.Channel) error {
+		settings.Prefix = prefix
+		return nil
+	})
+}
+
+func channelSettingAlias(ctx context.Context, s *channelSettings, args cmdmenu.Args) {
+	name := strings.ToLower(args.String("name"))
+	line := args.String("command")
+
+	if line == "" {
+		s.update("removed alias "+name, func(settings *db.Channel) error {
+			_, ok := settings.Aliases[name]
+			if !ok {
+				return errors.New("alias not found")
+			}
+			delete(settings.Aliases, name)
+			return nil
+		})
+		return
+	}
+
+	if findCommandByName(name) != nil {
+		s.msg.Reply("there's already a command called " + name)
 		return
 	}
 
-	i := slices.Index(server.Channels, channel)
-	if i == -1 {
-		msg.Client.Send(msg.Where, "not in channel")
+	// so aliases cant point to each other
+	target, _, _ := strings.Cut(line, " ")
+	if findCommandByName(strings.ToLower(target)) == nil {
+		s.msg.Reply("alias should start with a command")
 		return
 	}
 
-	server.Channels = slices.Delete(server.Channels, i, i+1)
+	s.update(name+" will run "+line, func(settings *db.Channel) error {
+		if settings.Aliases == nil {
+			settings.Aliases = map[string]string{}
+		}
+		settings.Aliases[name] = line
+		return nil
+	})
+}
+
+func channelSettingDisable(ctx context.Context, s *channelSettings, args cmdmenu.Args) {
+	name := args.String("command or category")
+
+	s.update(name+" disabled in "+s.channel, func(settings *db.Channel) error {
+		settings.Allowed = slices.DeleteFunc(settings.Allowed,
+			func(allowed string) bool { return allowed == name },
+		)
+		if !slices.Contains(settings.Disabled, name) {
+			settings.Disabled = append(settings.Disabled, name)
+		}
+		return nil
+	})
+}
+
+func channelSettingEnable(ctx context.Context, s *channelSettings, args cmdmenu.Args) {
+	name := args.String("command or category")
 
-	err = db.Servers.Put(args[0], server)
+	s.update(name+" enabled in "+s.channel, func(settings *db.Channel) error {
+		settings.Disabled = slices.DeleteFunc(settings.Disabled,
+			func(disabled string) bool { return disabled == name },
+		)
+		// otherwise everything is already allowed
+		if len(settings.Allowed) > 0 && !slices.Contains(settings.Allowed, name) {
+			settings.Allowed = append(settings.Allowed, name)
+		}
+		return nil
+	})
+}
+
+func channelSettingOnly(ctx context.Context, s *channelSettings, args cmdmenu.Args) {
+	var allowed []string
+	for _, value := range args.Values("command or category") {
+		name := value.(string)
+		if !slices.Contains(allowed, name) {
+			allowed = append(allowed, name)
+		}
+	}
+
+	reply := "all commands allowed in " + s.channel
+	if len(allowed) > 0 {
+		reply = "only " + strings.Join(allowed, ", ") + " allowed in " + s.channel
+	}
+
+	s.update(reply, func(settings *db.Channel) error {
+		settings.Allowed = allowed
+		return nil
+	})
+}
+
+func channelSettingQuiet(ctx context.Context, s *channelSettings, args cmdmenu.Args) {
+	quiet := args.Flag("on or off")
+
+	reply := "will reply to unknown commands in " + s.channel
+	if quiet {
+		reply = "won't reply to unknown commands in " + s.channel
+	}
+
+	s.update(reply, func(settings *db.Channel) error {
+		settings.Quiet = quiet
+		return nil
+	})
+}
+
+var adminChannelSettings = cmdmenu.Menu[channelSettings]{
+	Name:        "set",
+	Description: "change channel settings",
+	Commands: []cmdmenu.Runnable[channelSettings]{
+		&cmdmenu.Command[channelSettings]{
+			Name:        "trustbot",
+			Description: "answer commands from this bot",
+			Params:      []cmdmenu.Param{{Name: "nick"}},
+			Handle:      channelSettingTrustBot,
+		},
+		&cmdmenu.Command[channelSettings]{
+			Name:        "untrustbot",
+			Description: "ignore this bot again",
+			Params:      []cmdmenu.Param{{Name: "nick"}},
+			Handle:      channelSettingUntrustBot,
+		},
+		&cmdmenu.Command[channelSettings]{
+			Name:        "prefix",
+			Description: "command prefix, the server's if empty",
+			Params: []cmdmenu.Param{
+				{Name: "prefix", Type: paramPrefix, Optional: true},
+			},
+			Handle: channelSettingPrefix,
+		},
+		&cmdmenu.Command[channelSettings]{
+			Name:        "alias",
+			Description: "run a command by another name, removed if empty",
+			Params: []cmdmenu.Param{
+				{Name: "name"},
+				{Name: "command", Optional: true, Variadic: true},
+			},
+			Handle: channelSettingAlias,
+		},
+		&cmdmenu.Command[channelSettings]{
+			Name:        "disable",
+			Description: "turn off a command or category",
+			Params: []cmdmenu.Param{
+				{Name: "command or category", Type: paramCommandOrCategory},
+			},
+			Handle: channelSettingDisable,
+		},
+		&cmdmenu.Command[channelSettings]{
+			Name:        "enable",
+			Description: "turn a command or category back on",
+			Params: []cmdmenu.Param{
+				{Name: "command or category", Type: paramCommandOrCategory},
+			},
+			Handle: channelSettingEnable,
+		},
+		&cmdmenu.Command[channelSettings]{
+			Name:        "only",
+			Description: "allow nothing else, or everything if empty",
+			Params: []cmdmenu.Param{{
+				Name: "command or category", Type: paramCommandOrCategory,
+				Optional: true, Variadic: true,
+			}},
+			Handle: channelSettingOnly,
+		},
+		&cmdmenu.Command[channelSettings]{
+			Name:        "quiet",
+			Description: "dont reply to unknown commands",
+			Params:      []cmdmenu.Param{{Name: "on or off", Type: paramOnOff}},
+			Handle:      channelSettingQuiet,
+		},
+	},
+}
+
+// returns false if it replied with an error
+func getChannelSettings(
+	msg *irc.Message, server string, channel string,
+) (*channelSettings, bool) {
+	channel = manage.NormalizeChannel(channel)
+
+	_, err, exists := db.Servers.Get(server)
+	if err != nil {
+		msg.Reply("failed to get: " + err.Error())
+		return nil, false
+	}
+	if !exists {
+		msg.Reply("server not found")
+		return nil, false
+	}
+
+	settings, err := db.GetChannel(server, channel)
 	if err != nil {
-		msg.Client.Send(msg.Where, "failed to put: "+err.Error())
+		msg.Reply("failed to get channel settings: " + err.Error())
+		return nil, false
+	}
+
+	return &channelSettings{
+		msg: msg, server: server, channel: channel, settings: settings,
+	}, true
+}
+
+func adminChannelSet(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	s, ok := getChannelSettings(
+		msg, args.String("server"), args.String("channel"),
+	)
+	if !ok {
+		return
+	}
+
+	adminChannelSettings.Run(ctx, args.Strings("setting"), s, func(usage string) {
+		// settings menu doesnt know about the server and channel
+		cmdmenuUsage(msg)("channel set " + s.server + " " + s.channel +
+			strings.TrimPrefix(usage, "set"))
+	})
+}
+
+func joinOr(values []string, empty string) string {
+	if len(values) == 0 {
+		return empty
+	}
+	return strings.Join(values, ", ")
+}
+
+func adminChannelShow(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	s, ok := getChannelSettings(
+		msg, args.String("server"), args.String("channel"),
+	)
+	if !ok {
 		return
 	}
 
-	msg.Client.Send(msg.Where, "removed channel! will leave")
+	aliases := []string{}
+	for name, line := range s.settings.Aliases {
+		aliases = append(aliases, name+" = "+line)
+	}
+	slices.Sort(aliases)
+
+	out := fmt.Sprintf(
+		"%s on %s\n  prefix: %s\n  trusted bots: %s\n"+
+			"  disabled: %s\n  allowed: %s\n  quiet: %v",
+		ircf.BoldWhite.Format(s.channel),
+		ircf.BoldWhite.Format(s.server),
+		getPrefix(s.server, s.settings),
+		joinOr(s.settings.TrustedBots, "none"),
+		joinOr(s.settings.Disabled, "none"),
+		joinOr(s.settings.Allowed, "everything"),
+		s.settings.Quiet,
+	)
+	if len(aliases) > 0 {
+		out += "\n  aliases:\n    " + strings.Join(aliases, "\n    ")
+	}
 
-	irc.Sync()
+	msg.Reply(out)
 }
 
-func adminChannelSync(msg *irc.Message, args []string) {
-	msg.Client.SyncChannels()
-	msg.Client.Send(msg.Where, "will resync channels")
+var adminChannelParams = []cmdmenu.Param{
+	{Name: "server", Type: paramServer},
+	{Name: "channel", Type: paramChannel},
 }
 
 var adminChannel = cmdmenu.Menu[irc.Message]{
 	Name: "channel",
+	Examples: []string{
+		"channel join libera #mikogo",
+		"channel set libera #mikogo trustbot otherbot",
+	},
 	Commands: []cmdmenu.Runnable[irc.Message]{
 		&cmdmenu.Command[irc.Message]{
-			Name:   "list",
-			Handle: adminServerList,
+			Name:        "list",
+			Description: "list servers and their channels",
+			Handle:      adminServerList,
+		},
+		&cmdmenu.Command[irc.Message]{
+			Name:        "join",
+			Description: "join and remember a channel",
+			Params:      adminChannelParams,
+			Handle:      adminChannelJoin,
+		},
+		&cmdmenu.Command[irc.Message]{
+			Name:        "leave",
+			Exact:       true,
+			Description: "leave and forget a channel",
+			Params:      adminChannelParams,
+			Handle:      adminChannelLeave,
 		},
 		&cmdmenu.Command[irc.Message]{
-			Name:   "join",
-			Args:   2,
-			Usage:  "<server name> <channel name>",
-			Handle: adminChannelJoin,
+			Name:        "sync",
+			Description: "rejoin or leave channels to match the db",
+			Handle:      adminChannelSync,
 		},
 		&cmdmenu.Command[irc.Message]{
-			Name:   "leave",
-			Args:   2,
-			Usage:  "<server name> <channel name>",
-			Handle: adminChannelLeave,
+			Name:        "show",
+			Description: "show channel settings",
+			Params:      adminChannelParams,
+			Handle:      adminChannelShow,
 		},
 		&cmdmenu.Command[irc.Message]{
-			Name:   "sync",
-			Handle: adminChannelSync,
+			Name:        "set",
+			Description: "change channel settings",
+			Params: append(slices.Clone(adminChannelParams), cmdmenu.Param{
+				Name: "setting", Optional: true, Variadic: true,
+			}),
+			Submenu: &adminChannelSettings,
+			Handle:  adminChannelSet,
 		},
 	},
 }
 
-func handleAdminChannel(msg *irc.Message, args []string) {
-	adminChannel.Run(args[1:], msg, cmdmenuUsage(msg))
-}
-
 var CommandAdminChannel = Command{
 	Name:        "channel",
 	Category:    "admin",
 	Description: "manage channels",
-	Handle:      handleAdminChannel,
+	Menu:        &adminChannel,
 }
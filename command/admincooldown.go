@@ -0,0 +1,71 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/makinori/mikogo/cmdmenu"
+	"github.com/makinori/mikogo/irc"
+)
+
+func adminCooldownList(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	out := ""
+
+	for _, command := range commands {
+		if command.Cooldown == (Cooldown{}) {
+			continue
+		}
+		out += fmt.Sprintf("%s: user %s, channel %s, global %s\n",
+			command.Name, command.Cooldown.User,
+			command.Cooldown.Channel, command.Cooldown.Global,
+		)
+	}
+
+	active := activeCooldowns()
+	if len(active) > 0 {
+		out += "active:\n"
+		for _, cooldown := range active {
+			out += fmt.Sprintf("  %s: %s left\n",
+				cooldown.key, cooldown.left.Round(time.Second),
+			)
+		}
+	}
+
+	if out == "" {
+		msg.Reply("no cooldowns")
+		return
+	}
+
+	msg.Reply(strings.TrimSpace(out))
+}
+
+func adminCooldownClear(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	cleared := clearCooldowns(strings.ToLower(args.String("command")))
+	msg.Reply(fmt.Sprintf("cleared %d cooldowns", cleared))
+}
+
+var adminCooldown = cmdmenu.Menu[irc.Message]{
+	Name: "cooldown",
+	Commands: []cmdmenu.Runnable[irc.Message]{
+		&cmdmenu.Command[irc.Message]{
+			Name:        "list",
+			Description: "show cooldowns and who's waiting",
+			Handle:      adminCooldownList,
+		},
+		&cmdmenu.Command[irc.Message]{
+			Name:        "clear",
+			Description: "let everyone run a command again, or all of them",
+			Params:      []cmdmenu.Param{{Name: "command", Optional: true}},
+			Handle:      adminCooldownClear,
+		},
+	},
+}
+
+var CommandAdminCooldown = Command{
+	Name:        "cooldown",
+	Category:    "admin",
+	Description: "show and clear command cooldowns",
+	Menu:        &adminCooldown,
+}
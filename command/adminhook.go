@@ -0,0 +1,157 @@
+package command
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/makinori/mikogo/cmdmenu"
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/ircf"
+	"github.com/makinori/mikogo/manage"
+	"github.com/makinori/mikogo/webhook"
+)
+
+var paramHookTemplate = &cmdmenu.Type{
+	Name: "template",
+	Parse: func(value string) (any, error) {
+		value = strings.ToLower(value)
+		if !slices.Contains(webhook.TemplateNames(), value) {
+			return nil, errors.New("should be one of " +
+				strings.Join(webhook.TemplateNames(), ", "))
+		}
+		return value, nil
+	},
+}
+
+func adminHookAdd(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	// replies with the secret
+	if strings.HasPrefix(msg.Where, "#") {
+		msg.Reply("run this in a dm")
+		return
+	}
+
+	name := strings.ToLower(args.String("name"))
+	if strings.ContainsAny(name, "/?#%") {
+		msg.Reply("invalid hook name")
+		return
+	}
+
+	template := "generic"
+	if args.Has("template") {
+		template = args.String("template")
+	}
+
+	hook := db.Hook{
+		Server:   args.String("server"),
+		Channel:  args.String("channel"),
+		Secret:   rand.Text(),
+		Template: template,
+	}
+
+	err := db.Hooks.Add(name, hook)
+	if err != nil {
+		msg.Reply("failed to add: " + err.Error())
+		return
+	}
+
+	manage.Audit(actor(msg), "hook add", name+" "+hook.Server+" "+hook.Channel)
+
+	msg.Reply(fmt.Sprintf(
+		"hook added! POST to /hooks/%s\n  secret: %s\n  "+
+			"use it as the forge secret or as a bearer token",
+		name, ircf.BoldWhite.Format(hook.Secret),
+	))
+}
+
+func adminHookRemove(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	name := strings.ToLower(args.String("name"))
+
+	_, err, exists := db.Hooks.Get(name)
+	if err != nil {
+		msg.Reply("failed to get: " + err.Error())
+		return
+	}
+	if !exists {
+		msg.Reply("hook not found")
+		return
+	}
+
+	err = db.Hooks.Delete(name)
+	if err != nil {
+		msg.Reply("failed to remove: " + err.Error())
+		return
+	}
+
+	manage.Audit(actor(msg), "hook remove", name)
+
+	msg.Ack("hook removed")
+}
+
+func adminHookList(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	hooks, err := db.Hooks.GetAll()
+	if err != nil {
+		msg.Reply("failed to get all: " + err.Error())
+		return
+	}
+
+	out := ""
+	for name, hook := range hooks.AllFromFront() {
+		out += fmt.Sprintf("%s to %s on %s %s\n",
+			ircf.BoldWhite.Format(name),
+			ircf.BoldWhite.Format(hook.Channel),
+			ircf.BoldWhite.Format(hook.Server),
+			ircf.Color(98).Format(hook.Template),
+		)
+	}
+
+	if out == "" {
+		msg.Reply("no hooks")
+		return
+	}
+
+	msg.Reply(strings.TrimSpace(out))
+}
+
+var adminHook = cmdmenu.Menu[irc.Message]{
+	Name: "hook",
+	Examples: []string{
+		"hook add mikogo libera #mikogo github",
+	},
+	Commands: []cmdmenu.Runnable[irc.Message]{
+		&cmdmenu.Command[irc.Message]{
+			Name:        "add",
+			Description: "add a webhook. replies with the url and secret",
+			Params: []cmdmenu.Param{
+				{Name: "name"},
+				{Name: "server", Type: paramServer},
+				{Name: "channel", Type: paramChannel},
+				{Name: "template", Type: paramHookTemplate, Optional: true},
+			},
+			Handle: adminHookAdd,
+		},
+		&cmdmenu.Command[irc.Message]{
+			Name:        "remove",
+			Exact:       true,
+			Description: "remove a webhook",
+			Params:      []cmdmenu.Param{{Name: "name"}},
+			Handle:      adminHookRemove,
+		},
+		&cmdmenu.Command[irc.Message]{
+			Name:        "list",
+			Description: "list webhooks",
+			Handle:      adminHookList,
+		},
+	},
+}
+
+var CommandAdminHook = Command{
+	Name:        "hook",
+	Category:    "admin",
+	Description: "manage webhooks",
+	Menu:        &adminHook,
+}
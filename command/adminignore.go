@@ -0,0 +1,125 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/makinori/mikogo/cmdmenu"
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/ircf"
+)
+
+func adminIgnoreAdd(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	pattern := strings.ToLower(args.String("mask or $a:account"))
+	if !strings.HasPrefix(pattern, "$a:") && !strings.Contains(pattern, "!") {
+		msg.Reply("mask should be nick!user@host or $a:account")
+		return
+	}
+
+	ignore := db.Ignore{
+		Added:  time.Now(),
+		Reason: args.String("reason"),
+	}
+	if args.Has("for") {
+		ignore.Expires = ignore.Added.Add(args.Duration("for"))
+	}
+
+	err := putIgnore(pattern, ignore)
+	if err != nil {
+		msg.Reply("failed to put: " + err.Error())
+		return
+	}
+
+	msg.Ack("ignoring " + pattern)
+}
+
+func adminIgnoreRemove(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	pattern := strings.ToLower(args.String("mask or $a:account"))
+
+	err := removeIgnore(pattern)
+	if err != nil {
+		msg.Reply("failed to remove: " + err.Error())
+		return
+	}
+
+	msg.Ack("no longer ignoring " + pattern)
+}
+
+func adminIgnoreList(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	ignores, err := db.Ignores.GetAll()
+	if err != nil {
+		msg.Reply("failed to get all: " + err.Error())
+		return
+	}
+
+	out := ""
+	for pattern, ignore := range ignores.AllFromFront() {
+		if ignore.Expired() {
+			continue
+		}
+
+		out += ircf.BoldWhite.Format(pattern)
+		if ignore.Expires.IsZero() {
+			out += " forever"
+		} else {
+			out += fmt.Sprintf(" for %s",
+				time.Until(ignore.Expires).Round(time.Second),
+			)
+		}
+		if ignore.Auto {
+			out += ircf.Color(98).Format(" auto")
+		}
+		if ignore.Reason != "" {
+			out += ": " + ignore.Reason
+		}
+		out += "\n"
+	}
+
+	if out == "" {
+		msg.Reply("not ignoring anyone")
+		return
+	}
+
+	msg.Reply(strings.TrimSpace(out))
+}
+
+var adminIgnore = cmdmenu.Menu[irc.Message]{
+	Name: "ignore",
+	Examples: []string{
+		"ignore add -for=1h *!*@spam.host flooding",
+		"ignore add $a:someone",
+	},
+	Commands: []cmdmenu.Runnable[irc.Message]{
+		&cmdmenu.Command[irc.Message]{
+			Name:        "list",
+			Description: "list ignored masks and accounts",
+			Handle:      adminIgnoreList,
+		},
+		&cmdmenu.Command[irc.Message]{
+			Name:        "add",
+			Description: "ignore a mask or account, optionally for a while",
+			Params: []cmdmenu.Param{
+				{Name: "mask or $a:account"},
+				{Name: "reason", Optional: true, Variadic: true},
+			},
+			Flags:  []cmdmenu.Flag{{Name: "for", Type: cmdmenu.Duration}},
+			Handle: adminIgnoreAdd,
+		},
+		&cmdmenu.Command[irc.Message]{
+			Name:        "remove",
+			Description: "stop ignoring",
+			Params:      []cmdmenu.Param{{Name: "mask or $a:account"}},
+			Handle:      adminIgnoreRemove,
+		},
+	},
+}
+
+var CommandAdminIgnore = Command{
+	Name:        "ignore",
+	Category:    "admin",
+	Description: "manage ignored users",
+	Menu:        &adminIgnore,
+}
@@ -0,0 +1,158 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/makinori/mikogo/cmdmenu"
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/ircf"
+	"github.com/makinori/mikogo/manage"
+)
+
+const incidentListMax = 15
+
+func formatAgo(t time.Time) string {
+	return time.Since(t).Round(time.Second).String() + " ago"
+}
+
+func adminIncidentList(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	all := args.Flag("all")
+
+	incidents, err := db.Incidents.GetAll()
+	if err != nil {
+		msg.Reply("failed to get all: " + err.Error())
+		return
+	}
+
+	out := ""
+	shown := 0
+	hidden := 0
+
+	for key, incident := range incidents.AllFromBack() {
+		if incident.Acked && !all {
+			continue
+		}
+		if shown == incidentListMax {
+			hidden++
+			continue
+		}
+		shown++
+
+		id, _ := strconv.ParseUint(key, 10, 64)
+		out += irc.FormatIncident(id, incident) +
+			ircf.Color(98).Format(" "+formatAgo(incident.Last)) + "\n"
+	}
+
+	if shown == 0 {
+		msg.Reply("no incidents :)")
+		return
+	}
+	if hidden > 0 {
+		out += ircf.Color(98).Format(fmt.Sprintf("and %d more", hidden))
+	}
+
+	msg.Reply(strings.TrimSpace(out))
+}
+
+func getIncident(msg *irc.Message, id uint64) (db.Incident, bool) {
+	incident, err, exists := db.Incidents.Get(db.IncidentKey(id))
+	if err != nil {
+		msg.Reply("failed to get: " + err.Error())
+		return db.Incident{}, false
+	}
+	if !exists {
+		msg.Reply("incident not found")
+		return db.Incident{}, false
+	}
+	return incident, true
+}
+
+func adminIncidentShow(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	id := args.Value("id").(uint64)
+	incident, ok := getIncident(msg, id)
+	if !ok {
+		return
+	}
+
+	out := irc.FormatIncident(id, incident) + "\n"
+	out += fmt.Sprintf("first=%s last=%s count=%d acked=%t",
+		ircf.BoldWhite.Format(formatAgo(incident.First)),
+		ircf.BoldWhite.Format(formatAgo(incident.Last)),
+		incident.Count,
+		incident.Acked,
+	)
+	if !incident.LastDelivered.IsZero() {
+		out += " delivered=" +
+			ircf.BoldWhite.Format(formatAgo(incident.LastDelivered))
+	}
+
+	msg.Reply(out)
+}
+
+func adminIncidentAck(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	if args.Flag("all") {
+		acked, err := manage.AckAllIncidents()
+		if err != nil {
+			msg.Reply(err.Error())
+			return
+		}
+		msg.Reply(fmt.Sprintf("acknowledged %d incidents", acked))
+		return
+	}
+
+	if !args.Has("id") {
+		msg.Reply("need an incident id or -all")
+		return
+	}
+
+	err := manage.AckIncident(args.Value("id").(uint64))
+	if err != nil {
+		msg.Reply(err.Error())
+		return
+	}
+
+	msg.Ack("incident acknowledged")
+}
+
+var adminIncident = cmdmenu.Menu[irc.Message]{
+	Name: "incident",
+	Examples: []string{
+		"incident list -all",
+		"incident ack -all",
+	},
+	Commands: []cmdmenu.Runnable[irc.Message]{
+		&cmdmenu.Command[irc.Message]{
+			Name:        "list",
+			Description: "list unacknowledged incidents, or all of them",
+			Flags:       []cmdmenu.Flag{{Name: "all"}},
+			Handle:      adminIncidentList,
+		},
+		&cmdmenu.Command[irc.Message]{
+			Name:        "show",
+			Description: "show an incident in full",
+			Params:      []cmdmenu.Param{{Name: "id", Type: paramIncidentID}},
+			Handle:      adminIncidentShow,
+		},
+		&cmdmenu.Command[irc.Message]{
+			Name:        "ack",
+			Description: "acknowledge an incident, or all of them",
+			Params: []cmdmenu.Param{
+				{Name: "id", Type: paramIncidentID, Optional: true},
+			},
+			Flags:  []cmdmenu.Flag{{Name: "all"}},
+			Handle: adminIncidentAck,
+		},
+	},
+}
+
+var CommandAdminIncident = Command{
+	Name:        "incident",
+	Category:    "admin",
+	Description: "manage incidents",
+	Menu:        &adminIncident,
+}
@@ -0,0 +1,115 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/makinori/mikogo/cmdmenu"
+	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/ircf"
+	"github.com/makinori/mikogo/manage"
+)
+
+const (
+	rawStreamDuration = time.Second * 5
+	rawStreamMaxLines = 20
+)
+
+func getConnectedClient(msg *irc.Message, name string) *irc.Client {
+	client := irc.GetClient(name)
+	if client == nil {
+		msg.Reply("server not found")
+		return nil
+	}
+	if !client.Connected() {
+		msg.Reply("server not connected")
+		return nil
+	}
+	return client
+}
+
+// only numerics and lines answering the same verb and target,
+// so unrelated traffic isnt echoed back
+func isRawReply(sent *irc.Line, line *irc.Line) bool {
+	if len(line.Command) == 3 && strings.Trim(line.Command, "0123456789") == "" {
+		return true
+	}
+	if sent.Command == "PING" {
+		return line.Command == "PONG"
+	}
+	return line.Command == sent.Command && line.Param(0) == sent.Param(0)
+}
+
+func adminRaw(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	client := getConnectedClient(msg, args.String("server"))
+	if client == nil {
+		return
+	}
+
+	line := args.String("line")
+	manage.Audit(actor(msg), "raw", client.Name+": "+line)
+
+	sent := irc.ParseLine(line)
+
+	// buffer more than we need so the reader loop never blocks
+	lines := make(chan string, rawStreamMaxLines+1)
+	remove := client.Use(irc.Middleware{
+		Name: "raw",
+		Inbound: func(c *irc.Client, line *irc.Line) bool {
+			if !isRawReply(sent, line) {
+				return true
+			}
+			select {
+			case lines <- line.String():
+			default:
+			}
+			return true
+		},
+	})
+	defer remove()
+
+	client.WriteRaw(line)
+
+	timeout := time.After(rawStreamDuration)
+	received := 0
+
+	for {
+		select {
+		case line := <-lines:
+			if received == rawStreamMaxLines {
+				msg.Reply(ircf.Color(98).Format("more lines were cut off"))
+				return
+			}
+			received++
+			msg.Reply(line)
+		case <-timeout:
+			if received == 0 {
+				msg.Reply(ircf.Color(98).Format("no response"))
+			}
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+var adminRawCommand = cmdmenu.Command[irc.Message]{
+	Name: "raw",
+	Examples: []string{
+		"raw libera WHOIS someone",
+	},
+	Params: []cmdmenu.Param{
+		{Name: "server", Type: paramServer},
+		{Name: "line", Variadic: true},
+	},
+	Handle: adminRaw,
+}
+
+var CommandAdminRaw = Command{
+	Name:        "raw",
+	Category:    "admin",
+	Description: "write a line to a server and show what comes back",
+	Exact:       true,
+	Menu:        &adminRawCommand,
+}
@@ -0,0 +1,77 @@
+package command
+
+import (
+	"context"
+
+	"github.com/makinori/mikogo/cmdmenu"
+	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/manage"
+)
+
+func adminSay(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	client := getConnectedClient(msg, args.String("server"))
+	if client == nil {
+		return
+	}
+
+	target := args.String("target")
+	text := args.String("message")
+	manage.Audit(actor(msg), "say", client.Name+" "+target+": "+text)
+
+	client.Send(target, text)
+	msg.Ack("sent!")
+}
+
+func adminAct(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	client := getConnectedClient(msg, args.String("server"))
+	if client == nil {
+		return
+	}
+
+	target := args.String("target")
+	text := args.String("action")
+	manage.Audit(actor(msg), "act", client.Name+" "+target+": "+text)
+
+	client.Send(target, "\x01ACTION "+text+"\x01")
+	msg.Ack("sent!")
+}
+
+var adminSayCommand = cmdmenu.Command[irc.Message]{
+	Name: "say",
+	Examples: []string{
+		"say libera #mikogo hello there",
+	},
+	Params: []cmdmenu.Param{
+		{Name: "server", Type: paramServer},
+		{Name: "target"},
+		{Name: "message", Variadic: true},
+	},
+	Handle: adminSay,
+}
+
+var adminActCommand = cmdmenu.Command[irc.Message]{
+	Name: "act",
+	Examples: []string{
+		"act libera #mikogo waves",
+	},
+	Params: []cmdmenu.Param{
+		{Name: "server", Type: paramServer},
+		{Name: "target"},
+		{Name: "action", Variadic: true},
+	},
+	Handle: adminAct,
+}
+
+var CommandAdminSay = Command{
+	Name:        "say",
+	Category:    "admin",
+	Description: "send a message as me",
+	Menu:        &adminSayCommand,
+}
+
+var CommandAdminAct = Command{
+	Name:        "act",
+	Category:    "admin",
+	Description: "send an action as me",
+	Menu:        &adminActCommand,
+}
@@ -1,34 +1,33 @@
 package command
 
 import (
+	"context"
 	"fmt"
-	"slices"
 	"strings"
+	"time"
 
 	"github.com/makinori/mikogo/cmdmenu"
 	"github.com/makinori/mikogo/db"
 	"github.com/makinori/mikogo/irc"
 	"github.com/makinori/mikogo/ircf"
+	"github.com/makinori/mikogo/manage"
 )
 
-func adminServerList(msg *irc.Message, args []string) {
-	servers, err := db.Servers.GetAll()
+func adminServerList(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	servers, err := manage.ListServers()
 	if err != nil {
-		msg.Client.Send(msg.Where, "failed to get all: "+err.Error())
+		msg.Reply(err.Error())
 		return
 	}
 
 	out := ""
-	for name, server := range servers.AllFromBack() {
-		client := irc.GetClient(name)
-		currentChannels := client.CurrentChannels()
-
+	for _, server := range servers {
 		formattedChannels := make([]string, len(server.Channels))
 		for i, channel := range server.Channels {
-			if slices.Contains(currentChannels, channel) {
-				formattedChannels[i] = ircf.Color(98, 43).Format(channel)
+			if channel.Joined {
+				formattedChannels[i] = ircf.Color(98, 43).Format(channel.Name)
 			} else {
-				formattedChannels[i] = ircf.Color(98, 40).Format(channel)
+				formattedChannels[i] = ircf.Color(98, 40).Format(channel.Name)
 			}
 		}
 		if len(server.Channels) == 0 {
@@ -37,139 +36,314 @@ func adminServerList(msg *irc.Message, args []string) {
 			)
 		}
 
+		formattedAddresses := make([]string, len(server.Addresses))
+		for i, address := range server.Addresses {
+			if address == server.Address {
+				formattedAddresses[i] = ircf.BoldWhite.Format(address)
+			} else {
+				formattedAddresses[i] = ircf.Color(98).Format(address)
+			}
+		}
+
+		nick := ""
+		if server.Nick != "" {
+			nick = " nick=" + ircf.BoldWhite.Format(server.Nick)
+		}
+
+		uptime := ""
+		if !server.ConnectedAt.IsZero() {
+			uptime = " up=" + ircf.BoldWhite.Format(
+				time.Since(server.ConnectedAt).Round(time.Second).String(),
+			)
+		}
+
 		out += fmt.Sprintf(
-			"%s addr=%s state=%s\n  %s\n",
-			ircf.BoldWhite.Format(name),
-			ircf.BoldWhite.Format(server.Address),
-			client.FormattedState(),
+			"%s addr=%s%s state=%s%s reconnects=%s\n  %s\n",
+			ircf.BoldWhite.Format(server.Name),
+			strings.Join(formattedAddresses, ","),
+			nick,
+			irc.FormatState(server.State),
+			uptime,
+			ircf.BoldWhite.Format(fmt.Sprint(server.Reconnects)),
 			ircf.Bold().Format(strings.Join(formattedChannels, ", ")),
 		)
 	}
 
-	msg.Client.Send(msg.Where, strings.TrimSpace(out))
+	msg.Reply(strings.TrimSpace(out))
 }
 
-func adminServerAdd(msg *irc.Message, args []string) {
-	if args[0] == "home" {
-		msg.Client.Send(msg.Where, "cannot add home server")
-		return
+func formatBytes(n uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB"}
+	value := float64(n)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", n)
 	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
 
-	serverName, _, err, ok := db.GetServerByAddress(args[1])
-	if err != nil {
-		msg.Client.Send(msg.Where, "failed to get server by address: "+err.Error())
+func adminServerStats(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	name := args.String("server")
+
+	client := irc.GetClient(name)
+	if client == nil {
+		msg.Reply("server not found")
 		return
 	}
-	if ok {
-		msg.Client.Send(msg.Where, "server with same address already exists: "+serverName)
-		return
+
+	stats := client.Stats()
+
+	uptime := "not connected"
+	if !stats.ConnectedAt.IsZero() {
+		uptime = time.Since(stats.ConnectedAt).Round(time.Second).String() +
+			" on " + client.Address()
 	}
 
-	err = db.Servers.Add(
-		args[0], db.Server{Address: args[1]},
-	)
-	if err != nil {
-		msg.Client.Send(msg.Where, "failed to add: "+err.Error())
-		return
+	lastError := "none"
+	if stats.LastError != "" {
+		lastError = stats.LastError + " " + formatAgo(stats.LastErrorAt)
 	}
 
-	msg.Client.Send(msg.Where, "server added! will connect")
+	out := fmt.Sprintf("%s %s\n", ircf.BoldWhite.Format(name),
+		client.FormattedState(),
+	)
+	out += "  uptime: " + ircf.BoldWhite.Format(uptime) + "\n"
+	out += fmt.Sprintf("  connects: %s reconnects: %s\n",
+		ircf.BoldWhite.Format(fmt.Sprint(stats.Connects)),
+		ircf.BoldWhite.Format(fmt.Sprint(stats.Reconnects)),
+	)
+	out += "  last error: " + ircf.BoldWhite.Format(lastError) + "\n"
+	if stats.Lag > 0 {
+		out += "  lag: " + ircf.BoldWhite.Format(
+			stats.Lag.Round(time.Millisecond).String(),
+		) + "\n"
+	}
+	out += fmt.Sprintf("  in: %s lines %s\n",
+		ircf.BoldWhite.Format(fmt.Sprint(stats.LinesIn)),
+		ircf.BoldWhite.Format(formatBytes(stats.BytesIn)),
+	)
+	out += fmt.Sprintf("  out: %s lines %s\n",
+		ircf.BoldWhite.Format(fmt.Sprint(stats.LinesOut)),
+		ircf.BoldWhite.Format(formatBytes(stats.BytesOut)),
+	)
+	out += "  commands: " +
+		ircf.BoldWhite.Format(fmt.Sprint(stats.Commands)) + "\n"
+	out += ircf.Color(98).Format("counting since " + formatAgo(stats.Since))
 
-	irc.Sync()
+	msg.Reply(out)
 }
 
-func adminServerRemove(msg *irc.Message, args []string) {
-	if args[0] == "home" {
-		msg.Client.Send(msg.Where, "cannot remove home server")
-		return
-	}
-
-	err := db.Servers.Delete(args[0])
+func adminServerAdd(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	err := manage.AddServer(args.String("name"), args.Strings("address"))
 	if err != nil {
-		msg.Client.Send(msg.Where, "failed to remove: "+err.Error())
+		msg.Reply(err.Error())
 		return
 	}
 
-	msg.Client.Send(msg.Where, "server removed! will disconnect")
-
-	irc.Sync()
+	msg.Ack("server added! will connect")
 }
 
-func adminServerSetAddr(msg *irc.Message, args []string) {
-	if args[0] == "home" {
-		msg.Client.Send(msg.Where, "cannot update home server address")
+func adminServerRemove(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	err := manage.RemoveServer(args.String("server"))
+	if err != nil {
+		msg.Reply(err.Error())
 		return
 	}
 
-	serverName, _, err, ok := db.GetServerByAddress(args[1])
+	msg.Ack("server removed! will disconnect")
+}
+
+func adminServerSetAddr(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	err := manage.SetServerAddresses(
+		args.String("server"), args.Strings("address"),
+	)
 	if err != nil {
-		msg.Client.Send(msg.Where, "failed to get server by address: "+err.Error())
+		msg.Reply(err.Error())
 		return
 	}
-	if ok {
-		msg.Client.Send(msg.Where, "server with same address already exists: "+serverName)
-		return
+
+	msg.Ack("server addresses updated! will reconnect if needed")
+}
+
+// empty value resets to default
+func adminServerSetIdentity(
+	field string, description string,
+	set func(server *db.Server, value string),
+) *cmdmenu.Command[irc.Message] {
+	return &cmdmenu.Command[irc.Message]{
+		Name:        field,
+		Description: description,
+		Params: []cmdmenu.Param{
+			{Name: "server", Type: paramServer},
+			{Name: field, Optional: true, Variadic: true},
+		},
+		Handle: func(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+			value := args.String(field)
+			err := manage.UpdateServer(
+				args.String("server"), func(server *db.Server) {
+					set(server, value)
+				},
+			)
+			if err != nil {
+				msg.Reply(err.Error())
+				return
+			}
+
+			msg.Ack("server " + field + " updated! will reconnect")
+		},
 	}
+}
 
-	server, err, _ := db.Servers.Get(args[0])
+func adminServerSetPrefix(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	prefix := args.String("prefix")
+	err := manage.UpdateServer(args.String("server"), func(server *db.Server) {
+		server.Prefix = prefix
+	})
 	if err != nil {
-		msg.Client.Send(msg.Where, "failed to get: "+err.Error())
+		msg.Reply(err.Error())
 		return
 	}
 
-	server.Address = args[1]
-
-	err = db.Servers.Put(args[0], server)
-	if err != nil {
-		msg.Client.Send(msg.Where, "failed to update: "+err.Error())
-		return
+	if prefix == "" {
+		msg.Ack("server prefix reset")
+	} else {
+		msg.Ack("server prefix is now " + prefix)
 	}
+}
 
-	msg.Client.Send(msg.Where, "server address updated! will reconnect")
+func adminServerTrace(on bool) func(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	return func(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+		client := irc.GetClient(args.String("server"))
+		if client == nil {
+			msg.Reply("server not found")
+			return
+		}
+
+		err := client.SetTrace(on)
+		if err != nil {
+			msg.Reply("failed to set trace: " + err.Error())
+			return
+		}
 
-	irc.Sync()
+		if on {
+			msg.Reply("tracing to " + client.TracePath())
+		} else {
+			msg.Ack("stopped tracing")
+		}
+	}
 }
 
 var adminServer = cmdmenu.Menu[irc.Message]{
 	Name: "server",
+	Examples: []string{
+		"server add libera irc.libera.chat:6697",
+		"server set nick libera miko",
+	},
 	Commands: []cmdmenu.Runnable[irc.Message]{
 		&cmdmenu.Command[irc.Message]{
-			Name:   "list",
-			Handle: adminServerList,
+			Name:        "list",
+			Description: "list servers and their channels",
+			Handle:      adminServerList,
 		},
 		&cmdmenu.Command[irc.Message]{
-			Name:   "add",
-			Args:   2,
-			Usage:  "<name> <address>",
+			Name:        "stats",
+			Description: "show connection stats",
+			Params:      []cmdmenu.Param{{Name: "server", Type: paramServer}},
+			Handle:      adminServerStats,
+		},
+		&cmdmenu.Command[irc.Message]{
+			Name:        "add",
+			Description: "add a server and connect to it",
+			Params: []cmdmenu.Param{
+				{Name: "name"},
+				{Name: "address", Variadic: true},
+			},
 			Handle: adminServerAdd,
 		},
 		&cmdmenu.Command[irc.Message]{
-			Name:   "remove",
-			Args:   1,
-			Usage:  "<name>",
-			Handle: adminServerRemove,
+			Name:        "remove",
+			Exact:       true,
+			Description: "disconnect and forget a server",
+			Params:      []cmdmenu.Param{{Name: "server", Type: paramServer}},
+			Handle:      adminServerRemove,
 		},
 		&cmdmenu.Menu[irc.Message]{
-			Name: "set",
+			Name:        "trace",
+			Description: "log raw lines to a file",
 			Commands: []cmdmenu.Runnable[irc.Message]{
 				&cmdmenu.Command[irc.Message]{
-					Name:   "addr",
-					Args:   2,
-					Usage:  "<name> <address>",
+					Name:        "on",
+					Description: "start tracing",
+					Params:      []cmdmenu.Param{{Name: "server", Type: paramServer}},
+					Handle:      adminServerTrace(true),
+				},
+				&cmdmenu.Command[irc.Message]{
+					Name:        "off",
+					Description: "stop tracing",
+					Params:      []cmdmenu.Param{{Name: "server", Type: paramServer}},
+					Handle:      adminServerTrace(false),
+				},
+			},
+		},
+		&cmdmenu.Menu[irc.Message]{
+			Name:        "set",
+			Description: "change server settings",
+			Commands: []cmdmenu.Runnable[irc.Message]{
+				&cmdmenu.Command[irc.Message]{
+					Name:        "addr",
+					Description: "set addresses, tried in order",
+					Params: []cmdmenu.Param{
+						{Name: "server", Type: paramServer},
+						{Name: "address", Variadic: true},
+					},
 					Handle: adminServerSetAddr,
 				},
+				adminServerSetIdentity("nick", "nick to use, the default if empty",
+					func(server *db.Server, value string) {
+						server.Nick = value
+					},
+				),
+				adminServerSetIdentity("ident", "username to use, the nick if empty",
+					func(server *db.Server, value string) {
+						server.Ident = value
+					},
+				),
+				adminServerSetIdentity("realname", "realname to use, the nick if empty",
+					func(server *db.Server, value string) {
+						server.Realname = value
+					},
+				),
+				adminServerSetIdentity("pass", "server password",
+					func(server *db.Server, value string) {
+						server.Pass = value
+					},
+				),
+				adminServerSetIdentity("modes", "user modes to set once connected",
+					func(server *db.Server, value string) {
+						server.Modes = value
+					},
+				),
+				&cmdmenu.Command[irc.Message]{
+					Name:        "prefix",
+					Description: "command prefix, channels can override it",
+					Params: []cmdmenu.Param{
+						{Name: "server", Type: paramServer},
+						{Name: "prefix", Type: paramPrefix, Optional: true},
+					},
+					Handle: adminServerSetPrefix,
+				},
 			},
 		},
 	},
 }
 
-func handleAdminServer(msg *irc.Message, args []string) {
-	adminServer.Run(args[1:], msg, cmdmenuUsage(msg))
-}
-
 var CommandAdminServer = Command{
 	Name:        "server",
 	Category:    "admin",
 	Description: "manage servers",
-	Handle:      handleAdminServer,
+	Menu:        &adminServer,
 }
@@ -1,16 +1,15 @@
 package command
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/makinori/mikogo/cmdmenu"
 	"github.com/makinori/mikogo/irc"
 )
 
-func adminTestMsgsize(msg *irc.Message, args []string) {
-	msg.Client.Send(msg.Where,
-		"will send a few long messages and print byte length",
-	)
+func adminTestMsgsize(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	msg.Reply("will send a few long messages and print byte length")
 
 	overhead := len(msg.Client.MakePrivmsg(msg.Where, ""))
 
@@ -25,11 +24,9 @@ func adminTestMsgsize(msg *irc.Message, args []string) {
 		text := info + string(paddingBytes[len(info):])
 		out := msg.Client.MakePrivmsg(msg.Where, text)
 		if len(out) == size {
-			fmt.Fprint(msg.Client.Conn, out)
+			msg.Client.WriteRaw(out)
 		} else {
-			msg.Client.Send(msg.Where,
-				"failed to make message. should not happen",
-			)
+			msg.Reply("failed to make message. should not happen")
 		}
 	}
 
@@ -39,7 +36,7 @@ func adminTestMsgsize(msg *irc.Message, args []string) {
 	sendOfNBytes(500)
 	sendOfNBytes(512)
 
-	msg.Client.Send(msg.Where, "hopefully the 512 one came through\n"+
+	msg.Reply("hopefully the 512 one came through\n" +
 		"will now send a few of 513 bytes and higher",
 	)
 
@@ -49,28 +46,33 @@ func adminTestMsgsize(msg *irc.Message, args []string) {
 }
 
 var adminTest = cmdmenu.Menu[irc.Message]{
-	Name: "test",
+	Name:  "test",
+	Exact: true,
 	Commands: []cmdmenu.Runnable[irc.Message]{
 		&cmdmenu.Command[irc.Message]{
-			Name: "ping",
-			Handle: func(msg *irc.Message, args []string) {
-				msg.Client.Send(msg.Where, "pong!")
+			Name:        "ping",
+			Description: "reply with pong",
+			Handle: func(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+				msg.Reply("pong!")
 			},
 		},
 		&cmdmenu.Command[irc.Message]{
-			Name:   "msgsize",
-			Handle: adminTestMsgsize,
+			Name:        "msgsize",
+			Description: "find the longest message that gets through",
+			Handle:      adminTestMsgsize,
 		},
 		&cmdmenu.Command[irc.Message]{
-			Name: "clientpanic",
-			Handle: func(msg *irc.Message, args []string) {
-				msg.Client.PanicOnNextPing = true
-				msg.Client.Send(msg.Where, "will client panic on next ping")
+			Name:        "clientpanic",
+			Description: "panic the client on the next ping",
+			Handle: func(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+				msg.Client.PanicOnNextPing.Store(true)
+				msg.Reply("will client panic on next ping")
 			},
 		},
 		&cmdmenu.Command[irc.Message]{
-			Name: "commandpanic",
-			Handle: func(msg *irc.Message, args []string) {
+			Name:        "commandpanic",
+			Description: "panic in a command handler",
+			Handle: func(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
 				// should recover all the way back to command.go
 				panic("test panic")
 			},
@@ -78,13 +80,9 @@ var adminTest = cmdmenu.Menu[irc.Message]{
 	},
 }
 
-func handleAdminTest(msg *irc.Message, args []string) {
-	adminTest.Run(args[1:], msg, cmdmenuUsage(msg))
-}
-
 var CommandAdminTest = Command{
 	Name:        "test",
 	Category:    "admin",
 	Description: "various test functions",
-	Handle:      handleAdminTest,
+	Menu:        &adminTest,
 }
@@ -0,0 +1,10 @@
+package command
+
+import (
+	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/manage"
+)
+
+func actor(msg *irc.Message) manage.Actor {
+	return manage.Actor{Server: msg.Client.Name, Sender: msg.Sender}
+}
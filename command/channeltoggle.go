@@ -0,0 +1,31 @@
+package command
+
+import (
+	"slices"
+
+	"github.com/makinori/mikogo/db"
+)
+
+// owner only commands cant be turned off so they can't lock themselves out
+func commandEnabled(settings db.Channel, command *Command) bool {
+	if slices.Contains(ownerOnlyCategories, command.Category) {
+		return true
+	}
+
+	matches := func(name string) bool {
+		return name == command.Name || name == command.Category
+	}
+
+	if slices.ContainsFunc(settings.Disabled, matches) {
+		return false
+	}
+
+	return len(settings.Allowed) == 0 ||
+		slices.ContainsFunc(settings.Allowed, matches)
+}
+
+func isCommandOrCategory(name string) bool {
+	return slices.ContainsFunc(commands, func(command *Command) bool {
+		return name == command.Name || name == command.Category
+	})
+}
@@ -1,25 +1,62 @@
 package command
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"regexp"
 	"slices"
 	"strings"
+	"time"
 
+	"github.com/makinori/mikogo/cmdmenu"
+	"github.com/makinori/mikogo/db"
 	"github.com/makinori/mikogo/env"
 	"github.com/makinori/mikogo/irc"
-)
-
-const (
-	prefix = "m!"
+	"github.com/makinori/mikogo/metrics"
 )
 
 type Command struct {
 	Name        string
 	Category    string
 	Description string
-	Handle      func(msg *irc.Message, args []string)
+	// has to be typed in full, for dangerous commands
+	Exact    bool
+	Cooldown Cooldown
+	// handlers should stop once the context is done. defaults to DEFAULT_TIMEOUT
+	Timeout time.Duration
+	// either a handler or a cmdmenu menu or command to run with the rest
+	Handle func(ctx context.Context, msg *irc.Message, args []string)
+	Menu   cmdmenu.Runnable[irc.Message]
+}
+
+const DEFAULT_TIMEOUT = time.Minute
+
+func (c *Command) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DEFAULT_TIMEOUT
+}
+
+func (c *Command) run(ctx context.Context, msg *irc.Message, args []string) {
+	if c.Handle != nil {
+		startCooldown(msg, c)
+		c.Handle(ctx, msg, args)
+		return
+	}
+	// usage errors shouldnt use up the cooldown
+	ctx = cmdmenu.OnHandle(ctx, func() { startCooldown(msg, c) })
+	c.Menu.Run(ctx, args[1:], msg, cmdmenuUsage(msg))
+}
+
+func (c *Command) Help() cmdmenu.HelpEntry {
+	entry := cmdmenu.HelpEntry{Name: c.Name}
+	if c.Menu != nil {
+		entry = c.Menu.Help()
+	}
+	entry.Description = c.Description
+	return entry
 }
 
 var (
@@ -31,27 +68,126 @@ var (
 	}
 
 	whiteSpaceRegexp = regexp.MustCompile(`\s+`)
+
+	// only known command names as labels so users cant blow up cardinality
+	metricCommandInvocations = metrics.NewCounter(
+		"mikogo_command_invocations_total",
+		"Commands run or refused by name.", "command", "allowed",
+	)
+	metricCommandDuration = metrics.NewHistogram(
+		"mikogo_command_duration_seconds",
+		"How long command handlers took.", metrics.DefaultBuckets, "command",
+	)
+	metricCommandPanics = metrics.NewCounter(
+		"mikogo_command_panics_total", "Command handlers that panicked.",
+		"command",
+	)
 )
 
 func init() {
 	commands = append(commands,
 		&CommandGeneralHelp,
 		&CommandGeneralInfo,
+		&CommandGeneralFeed,
+		&CommandGeneralJobs,
+		&CommandGeneralCancel,
 
 		&CommandFunImage,
 
 		&CommandAdminServer,
 		&CommandAdminChannel,
 		&CommandAdminTest,
+		&CommandAdminRaw,
+		&CommandAdminSay,
+		&CommandAdminAct,
+		&CommandAdminIncident,
+		&CommandAdminIgnore,
+		&CommandAdminHook,
+		&CommandAdminCooldown,
 	)
 }
 
-func sendUnknownCommand(msg *irc.Message) {
-	if strings.HasPrefix(msg.Where, "#") {
-		msg.Client.Send(msg.Where, "unknown command. type "+prefix+"help")
-	} else {
-		msg.Client.Send(msg.Where, "unknown command. type help")
+func sendUnknownCommand(msg *irc.Message, suggestions []string) {
+	usedPrefix := shownPrefix(msg)
+
+	out := "unknown command. "
+	if len(suggestions) > 0 {
+		out += cmdmenu.DidYouMean(usedPrefix, suggestions) + " "
+	}
+	msg.Reply(out + "type " + usedPrefix + "help")
+}
+
+func findCommandByName(name string) *Command {
+	for _, command := range commands {
+		if command.Name == name {
+			return command
+		}
+	}
+	return nil
+}
+
+// exact names for anyone so refusals get reported, but prefixes only
+// resolve to commands the sender can run and only those shown are suggested.
+// commands turned off in the channel are never found
+func findCommand(
+	msg *irc.Message, settings db.Channel, name string,
+) (*Command, []string) {
+	command := findCommandByName(name)
+	if command != nil {
+		if !commandEnabled(settings, command) {
+			return nil, nil
+		}
+		return command, nil
+	}
+
+	runnable := []*Command{}
+	runnableNames := []string{}
+	shownNames := []string{}
+
+	for _, command := range commands {
+		if !commandEnabled(settings, command) {
+			continue
+		}
+		canRun, canShow := canSenderRunCommand(msg, command)
+		if canRun {
+			runnable = append(runnable, command)
+			runnableNames = append(runnableNames, command.Name)
+		}
+		if canShow {
+			shownNames = append(shownNames, command.Name)
+		}
+	}
+
+	i, _ := cmdmenu.Match(name, runnableNames, func(name string) bool {
+		return !runnable[slices.Index(runnableNames, name)].Exact
+	})
+	if i > -1 {
+		return runnable[i], nil
+	}
+
+	_, suggestions := cmdmenu.Match(name, shownNames, func(string) bool {
+		return false
+	})
+	return nil, suggestions
+}
+
+// settings for where the message was sent. defaults in direct messages
+func messageChannel(msg *irc.Message) db.Channel {
+	if !strings.HasPrefix(msg.Where, "#") {
+		return db.Channel{}
+	}
+
+	settings, err := db.GetChannel(msg.Client.Name, msg.Where)
+	if err != nil {
+		slog.Error("failed to get channel settings", "err", err)
 	}
+	return settings
+}
+
+func isTrustedBot(msg *irc.Message, settings db.Channel) bool {
+	return slices.ContainsFunc(settings.TrustedBots, func(nick string) bool {
+		return strings.EqualFold(nick, msg.Sender)
+	})
 }
 
 func canSenderRunCommand(
@@ -62,7 +198,7 @@ func canSenderRunCommand(
 	}
 
 	// only allow on home server incase there's a malicious server
-	if msg.Client.Address != env.HOME_SERVER {
+	if msg.Client.Name != "home" {
 		return false, false
 	}
 
@@ -71,51 +207,85 @@ func canSenderRunCommand(
 	return
 }
 
+// runs the handler as a job. recovers so a panic doesnt take the bot down
+func runJob(msg *irc.Message, command *Command, args []string) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		metricCommandPanics.Inc(command.Name)
+		msg.Reply(fmt.Sprintf("command panicked: %v", r))
+		slog.Warn("command panicked", "err", r)
+	}()
+
+	start := time.Now()
+
+	ctx, done := startJob(msg, command, args)
+	defer done()
+	command.run(ctx, msg, args)
+
+	metricCommandDuration.Observe(time.Since(start).Seconds(), command.Name)
+}
+
 func Run(msg *irc.Message) {
 	defer func() {
 		r := recover()
 		if r == nil {
 			return
 		}
-		msg.Client.Send(msg.Where, fmt.Sprintf("command panicked: %v", r))
+		metricCommandPanics.Inc("")
+		msg.Reply(fmt.Sprintf("command panicked: %v", r))
 		slog.Warn("command panicked", "err", r)
 	}()
 
-	if strings.HasPrefix(msg.Where, "#") &&
-		!strings.HasPrefix(msg.Message, prefix) {
+	settings := messageChannel(msg)
+
+	line, forUs := trimPrefix(msg, getPrefix(msg.Client.Name, settings))
+	if !forUs {
 		return
 	}
 
-	args := whiteSpaceRegexp.Split(strings.TrimSpace(msg.Message), -1)
-	args[0] = strings.TrimPrefix(args[0], prefix)
+	// bots replying to each other can loop forever
+	if msg.IsBot() && !isTrustedBot(msg, settings) {
+		return
+	}
 
-	if len(args) == 0 {
-		sendUnknownCommand(msg)
+	if checkFlood(msg) {
 		return
 	}
 
-	name := strings.ToLower(args[0])
+	args := whiteSpaceRegexp.Split(line, -1)
+	args = expandAlias(args, settings)
 
-	foundCommand := -1
-	for i := range commands {
-		if commands[i].Name == name {
-			foundCommand = i
-			break
+	command, suggestions := findCommand(
+		msg, settings, strings.ToLower(args[0]),
+	)
+	if command == nil {
+		if !settings.Quiet {
+			sendUnknownCommand(msg, suggestions)
 		}
-	}
-	if foundCommand == -1 {
-		sendUnknownCommand(msg)
 		return
 	}
+	name := command.Name
+
+	msg.Client.CountCommand()
+
+	canRun, _ := canSenderRunCommand(msg, command)
+	metricCommandInvocations.Inc(name, fmt.Sprint(canRun))
+	addActivity(msg, command, args, canRun)
 
-	canRun, _ := canSenderRunCommand(msg, commands[foundCommand])
 	if canRun {
-		commands[foundCommand].Handle(msg, args)
+		if checkCooldown(msg, command) {
+			return
+		}
+		// holds the dispatch slot until done, so the channel stays in order.
+		// jobs can still be cancelled from a dm
+		runJob(msg, command, args)
 	} else {
-		msg.Client.Send(msg.Where, "sorry you can't run that command :(")
-		irc.ReportIncident(fmt.Sprintf(
-			`"%s" tried to run "%s" on "%s"`,
-			msg.Sender, msg.Message, msg.Client.Address,
+		msg.Reply("sorry you can't run that command :(")
+		irc.ReportIncident(irc.SeverityWarn, msg.Client.Name, fmt.Sprintf(
+			`"%s" tried to run "%s"`, msg.Sender, msg.Message,
 		))
 	}
 }
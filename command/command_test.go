@@ -0,0 +1,513 @@
+package command
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/env"
+	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/irc/irctest"
+	"github.com/makinori/mikogo/manage"
+)
+
+var testHome *irctest.Home
+
+func TestMain(m *testing.M) {
+	irc.RECONNECT_DURATION = time.Millisecond * 100
+
+	var err error
+	testHome, err = irctest.StartHome("mikogo-command", irc.Sync)
+	if err != nil {
+		panic(err)
+	}
+
+	irc.GlobalHandleMessage = Run
+	irc.Use(IgnoreMiddleware)
+
+	code := m.Run()
+
+	testHome.Close()
+	os.Exit(code)
+}
+
+// connects a client to a fresh server and waits until it has joined.
+// messages sent to the same channel are handled in order
+func connectTestServer(
+	t *testing.T, name string, channels ...string,
+) *irctest.Conn {
+	t.Helper()
+
+	server := irctest.NewTestServer(t)
+
+	// every test user shares the same host
+	floodHistoryMutex.Lock()
+	clear(floodHistory)
+	floodHistoryMutex.Unlock()
+
+	err := db.Servers.Put(name, db.Server{
+		Addresses: []string{server.Addr},
+		Channels:  channels,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	irc.Sync()
+	t.Cleanup(func() {
+		db.Servers.Delete(name)
+		irc.Sync()
+	})
+
+	conn := server.Accept(t)
+	conn.ExpectRegistered(t)
+	for _, channel := range channels {
+		conn.Expect(t, `^JOIN `+channel+`$`)
+	}
+
+	return conn
+}
+
+func TestReplyIsThreaded(t *testing.T) {
+	conn := connectTestServer(t, "threaded", "#test")
+
+	conn.Privmsg("msgid=abc", "alice", "#test", "m!info")
+	conn.Expect(t, `^@\+draft/reply=abc BATCH \+\d+ draft/multiline #test$`)
+	conn.Expect(t, `PRIVMSG #test :hi im mikogo`)
+}
+
+func TestUnknownCommand(t *testing.T) {
+	conn := connectTestServer(t, "unknown", "#test")
+
+	conn.Privmsg("", "alice", "#test", "m!nope")
+	conn.Expect(t, `PRIVMSG #test :unknown command\. type m!help$`)
+
+	conn.Privmsg("", "alice", "mikogo", "nope")
+	conn.Expect(t, `PRIVMSG alice :unknown command\. type help$`)
+}
+
+func TestOwnerOnly(t *testing.T) {
+	home := testHome.Conn(t)
+	conn := connectTestServer(t, "owneronly")
+
+	// owner nick means nothing outside of home
+	conn.Privmsg("", env.OWNER, "mikogo", "server list")
+	conn.Expect(t, `PRIVMSG `+env.OWNER+` :sorry you can't run that command`)
+	home.Expect(t, `PRIVMSG `+env.OWNER+` :.*incident.*tried to run`)
+
+	home.Privmsg("", env.OWNER, "mikogo", "test ping")
+	home.Expect(t, `PRIVMSG `+env.OWNER+` :pong!$`)
+}
+
+func TestRawOnlyShowsReplies(t *testing.T) {
+	home := testHome.Conn(t)
+	conn := connectTestServer(t, "raw", "#test")
+
+	home.Privmsg("", env.OWNER, "mikogo", "raw raw WHOIS bob")
+	conn.Expect(t, `^WHOIS bob$`)
+
+	conn.Privmsg("", "alice", "#test", "unrelated chatter")
+	conn.Send(":%s 318 mikogo bob :marker", irctest.Name)
+	home.Expect(t, `PRIVMSG `+env.OWNER+` :.* 318 mikogo bob :marker$`)
+
+	for _, line := range home.Log() {
+		if strings.Contains(line, "unrelated chatter") {
+			t.Fatalf("raw leaked unrelated traffic: %q", line)
+		}
+	}
+}
+
+func TestInvalidIdentity(t *testing.T) {
+	home := testHome.Conn(t)
+	connectTestServer(t, "identity")
+
+	home.Privmsg("", env.OWNER, "mikogo", "server set nick identity a b")
+	home.Expect(t, `PRIVMSG `+env.OWNER+` :invalid nick$`)
+
+	home.Privmsg("", env.OWNER, "mikogo", "server set ident identity :a")
+	home.Expect(t, `PRIVMSG `+env.OWNER+` :invalid ident$`)
+
+	server, _, _ := db.Servers.Get("identity")
+	if server.Nick != "" || server.Ident != "" {
+		t.Fatalf("invalid identity was saved: %+v", server)
+	}
+}
+
+func TestServerEditKeepsLastAddress(t *testing.T) {
+	connectTestServer(t, "edits")
+
+	saved := make(chan struct{})
+	err := manage.UpdateServer("edits", func(server *db.Server) {
+		// like the client saving its address on connect mid edit
+		go func() {
+			defer close(saved)
+			db.Servers.Update("edits", func(server *db.Server) error {
+				server.LastAddress = "last.test:6697"
+				return nil
+			})
+		}()
+		// only lands first if the edit isnt one transaction
+		select {
+		case <-saved:
+		case <-time.After(time.Millisecond * 50):
+		}
+		server.Nick = "edited"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-saved
+
+	server, _, _ := db.Servers.Get("edits")
+	if server.Nick != "edited" || server.LastAddress != "last.test:6697" {
+		t.Fatalf("lost an edit: %+v", server)
+	}
+}
+
+func TestIncidentCommands(t *testing.T) {
+	home := testHome.Conn(t)
+
+	id, err := db.AddIncident(db.Incident{
+		Severity: irc.SeverityWarn, Server: "home", Message: "ack me",
+		First: time.Now(), Last: time.Now(), Count: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	home.Privmsg("", env.OWNER, "mikogo", "incident list foo")
+	home.Expect(t, `PRIVMSG `+env.OWNER+` :usage: incident list \[-all\]`)
+
+	home.Privmsg("", env.OWNER, "mikogo", "incident ack nope")
+	home.Expect(t, `PRIVMSG `+env.OWNER+` :  .*invalid incident id`)
+
+	home.Privmsg("", env.OWNER, "mikogo", fmt.Sprintf("incident ack #%d", id))
+	home.Expect(t, `PRIVMSG `+env.OWNER+` :incident acknowledged`)
+
+	incident, _, _ := db.Incidents.Get(db.IncidentKey(id))
+	if !incident.Acked {
+		t.Fatal("incident wasnt acked")
+	}
+}
+
+func TestActivityCommand(t *testing.T) {
+	for line, expected := range map[string]string{
+		"image https://example.com/secret.png": "image",
+		"server set nick libera someone":       "server set",
+		"server se libera":                     "server set",
+		"server hunter2":                       "server",
+		"raw libera PASS hunter2":              "raw",
+	} {
+		args := strings.Fields(line)
+		command := findCommandByName(args[0])
+		if command == nil {
+			t.Fatalf("command not found: %s", args[0])
+		}
+		got := activityCommand(command, args)
+		if got != expected {
+			t.Errorf("%q: expected %q, got %q", line, expected, got)
+		}
+	}
+}
+
+func TestBotsIgnored(t *testing.T) {
+	conn := connectTestServer(t, "bots", "#test")
+
+	conn.Privmsg("bot;msgid=bot1", "otherbot", "#test", "m!nope")
+	conn.Privmsg("msgid=alice1", "alice", "#test", "m!nope")
+	conn.Expect(t, `^@\+draft/reply=alice1 .*unknown command`)
+
+	for _, line := range conn.Log() {
+		if strings.Contains(line, "reply=bot1") {
+			t.Fatal("replied to a bot")
+		}
+	}
+
+	err := db.PutChannel("bots", "#test", db.Channel{
+		TrustedBots: []string{"OtherBot"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Privmsg("bot;msgid=bot2", "otherbot", "#test", "m!nope")
+	conn.Expect(t, `^@\+draft/reply=bot2 .*unknown command`)
+}
+
+func TestHelp(t *testing.T) {
+	home := testHome.Conn(t)
+	conn := connectTestServer(t, "help", "#test")
+
+	conn.Privmsg("", "alice", "#test", "m!help feed add")
+	conn.Expect(t, `PRIVMSG #test :feed add <url>: announce new entries here$`)
+
+	// admin commands are hidden from everyone else
+	conn.Privmsg("", "alice", "#test", "m!help server")
+	conn.Expect(t, `PRIVMSG #test :no help for server$`)
+
+	home.Privmsg("", env.OWNER, "mikogo", "help server set")
+	home.Expect(t, `PRIVMSG `+env.OWNER+` :server set: change server settings$`)
+	home.Expect(t, `PRIVMSG `+env.OWNER+` :  addr <server> <address\.\.\.>: `)
+}
+
+func TestDidYouMean(t *testing.T) {
+	home := testHome.Conn(t)
+	conn := connectTestServer(t, "didyoumean", "#test")
+
+	conn.Privmsg("", "alice", "#test", "m!imgae")
+	conn.Expect(t,
+		`PRIVMSG #test :unknown command\. did you mean m!image\? type m!help$`,
+	)
+
+	// admin commands arent suggested to everyone else
+	conn.Privmsg("", "alice", "#test", "m!sever")
+	conn.Expect(t, `PRIVMSG #test :unknown command\. type m!help$`)
+
+	conn.Privmsg("", "alice", "#test", "m!ima")
+	conn.Expect(t, `PRIVMSG #test :usage: m!image \[-nodither\] <url>$`)
+
+	home.Privmsg("", env.OWNER, "mikogo", "serv st nope")
+	home.Expect(t, `PRIVMSG `+env.OWNER+` :usage: server stats <server>$`)
+
+	// removing a server has to be typed in full
+	home.Privmsg("", env.OWNER, "mikogo", "server rem nope")
+	home.Expect(t, `PRIVMSG `+env.OWNER+` :  did you mean server remove\?$`)
+}
+
+func TestPrefixAndAliases(t *testing.T) {
+	conn := connectTestServer(t, "prefix", "#test", "#other")
+
+	server, err, _ := db.Servers.Get("prefix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Prefix = "?"
+	err = db.Servers.Put("prefix", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.PutChannel("prefix", "#test", db.Channel{
+		Prefix:  "!",
+		Aliases: map[string]string{"img": "image -nodither"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Privmsg("", "alice", "#test", "m!nope")
+	conn.Privmsg("", "alice", "#test", "!nope")
+	conn.Expect(t, `PRIVMSG #test :unknown command\. type !help$`)
+
+	conn.Privmsg("", "alice", "#test", "Mikogo: nope")
+	conn.Expect(t, `PRIVMSG #test :unknown command\. type !help$`)
+
+	conn.Privmsg("", "alice", "#test", "!img")
+	conn.Expect(t, `PRIVMSG #test :usage: !image \[-nodither\] <url>$`)
+
+	conn.Privmsg("", "alice", "#other", "?nope")
+	conn.Expect(t, `PRIVMSG #other :unknown command\. type \?help$`)
+
+	for _, line := range conn.Log() {
+		if strings.Contains(line, "type m!help") {
+			t.Fatal("answered the default prefix")
+		}
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	admins["cooldown"] = []string{"admin"}
+	t.Cleanup(func() {
+		delete(admins, "cooldown")
+		clearCooldowns("")
+	})
+
+	notImage := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("not an image"))
+		},
+	))
+	defer notImage.Close()
+
+	conn := connectTestServer(t, "cooldown", "#test")
+
+	// usage errors dont start the cooldown
+	conn.Privmsg("", "alice", "#test", "m!image")
+	conn.Expect(t, `PRIVMSG #test :usage: m!image`)
+	conn.Privmsg("", "alice", "#test", "m!image")
+	conn.Expect(t, `PRIVMSG #test :usage: m!image`)
+
+	conn.Privmsg("", "alice", "#test", "m!image "+notImage.URL)
+	conn.Expect(t, `PRIVMSG #test :failed to decode image`)
+
+	conn.Privmsg("", "alice", "#test", "m!image")
+	conn.Privmsg("", "alice", "#test", "m!image")
+	conn.Privmsg("account=admin", "bob", "#test", "m!image")
+	conn.Expect(t, `PRIVMSG #test :slow down! try again in \d+s$`)
+	conn.Expect(t, `PRIVMSG #test :usage: m!image`)
+
+	warned := 0
+	for _, line := range conn.Log() {
+		if strings.Contains(line, "slow down") {
+			warned++
+		}
+	}
+	if warned != 1 {
+		t.Fatalf("expected to be warned once, got %d", warned)
+	}
+}
+
+func TestChannelToggles(t *testing.T) {
+	conn := connectTestServer(t, "toggles", "#test")
+
+	err := db.PutChannel("toggles", "#test", db.Channel{
+		Disabled: []string{"fun"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Privmsg("", "alice", "#test", "m!image")
+	conn.Privmsg("", "alice", "#test", "m!help")
+	conn.Expect(t, `PRIVMSG #test :unknown command\. type m!help$`)
+	conn.Expect(t, `PRIVMSG #test :general:$`)
+
+	for _, line := range conn.Log() {
+		if strings.Contains(line, "image") {
+			t.Fatalf("disabled command showed up: %s", line)
+		}
+	}
+	before := len(conn.Log())
+
+	err = db.PutChannel("toggles", "#test", db.Channel{
+		Allowed: []string{"help"},
+		Quiet:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Privmsg("", "alice", "#test", "m!info")
+	conn.Privmsg("", "alice", "#test", "m!help")
+	conn.Expect(t, `PRIVMSG #test :general:$`)
+	conn.Expect(t, `PRIVMSG #test :  help: `)
+
+	for _, line := range conn.Log()[before:] {
+		if strings.Contains(line, "info") || strings.Contains(line, "unknown") {
+			t.Fatalf("disabled command showed up: %s", line)
+		}
+	}
+}
+
+// never responds, until the request is cancelled or the test ends
+func slowServer(t *testing.T) *httptest.Server {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+		},
+	))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(release) })
+	return slow
+}
+
+func TestCancelJob(t *testing.T) {
+	slow := slowServer(t)
+
+	conn := connectTestServer(t, "jobs", "#test")
+	t.Cleanup(func() {
+		clearCooldowns("")
+		if t.Failed() {
+			t.Log(conn.Log())
+		}
+	})
+
+	conn.Privmsg("", "alice", "#test", "m!image "+slow.URL)
+	conn.Expect(t, `^@\+typing=active TAGMSG #test$`)
+
+	// dms are queued separately from the channel
+	conn.Privmsg("", "alice", "mikogo", "jobs")
+	conn.Expect(t, `PRIVMSG alice :.* image `+regexp.QuoteMeta(slow.URL)+` for `)
+
+	conn.Privmsg("", "bob", "mikogo", "jobs")
+	conn.Expect(t, `PRIVMSG bob :no jobs running$`)
+
+	id := 0
+	jobsMutex.Lock()
+	for _, j := range jobs {
+		if j.Command == "image" && j.Server == "jobs" {
+			id = j.ID
+		}
+	}
+	jobsMutex.Unlock()
+
+	conn.Privmsg("", "alice", "mikogo", fmt.Sprintf("cancel %d", id))
+	conn.Expect(t, fmt.Sprintf(`PRIVMSG alice :cancelled %d$`, id))
+	conn.Expect(t, `PRIVMSG #test :stopped: cancelled by alice$`)
+}
+
+func TestPruneCooldowns(t *testing.T) {
+	t.Cleanup(func() { clearCooldowns("") })
+
+	cooldownsMutex.Lock()
+	cooldowns["prune user old"] = &cooldownState{
+		until: time.Now().Add(-time.Second),
+	}
+	cooldowns["prune user new"] = &cooldownState{
+		until: time.Now().Add(time.Minute),
+	}
+	cooldownsMutex.Unlock()
+
+	pruneCooldowns()
+
+	cooldownsMutex.Lock()
+	defer cooldownsMutex.Unlock()
+	if _, ok := cooldowns["prune user old"]; ok {
+		t.Fatal("expired cooldown wasnt pruned")
+	}
+	if _, ok := cooldowns["prune user new"]; !ok {
+		t.Fatal("active cooldown was pruned")
+	}
+}
+
+func TestCancelFeedAdd(t *testing.T) {
+	admins["feedjobs"] = []string{"admin"}
+	t.Cleanup(func() { delete(admins, "feedjobs") })
+
+	slow := slowServer(t)
+
+	conn := connectTestServer(t, "feedjobs", "#test")
+
+	conn.Privmsg("account=admin", "alice", "#test", "m!feed add "+slow.URL)
+
+	id := 0
+	for range 100 {
+		jobsMutex.Lock()
+		for _, j := range jobs {
+			if j.Command == "feed" && j.Server == "feedjobs" {
+				id = j.ID
+			}
+		}
+		jobsMutex.Unlock()
+		if id != 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if id == 0 {
+		t.Fatal("feed add job never started")
+	}
+
+	conn.Privmsg("account=admin", "alice", "mikogo", fmt.Sprintf("cancel %d", id))
+	conn.Expect(t, `PRIVMSG #test :stopped: cancelled by alice$`)
+}
@@ -0,0 +1,174 @@
+package command
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/makinori/mikogo/irc"
+)
+
+// zero means no cooldown
+type Cooldown struct {
+	User    time.Duration
+	Channel time.Duration
+	Global  time.Duration
+}
+
+type cooldownState struct {
+	until time.Time
+	// so only the first attempt gets a reply
+	warned bool
+}
+
+var (
+	// command, scope and who
+	cooldowns      = map[string]*cooldownState{}
+	cooldownsMutex = sync.Mutex{}
+)
+
+func cooldownKey(command string, scope string, who string) string {
+	return command + " " + scope + " " + who
+}
+
+func cooldownKeys(
+	msg *irc.Message, command *Command,
+) map[string]time.Duration {
+	if command.Cooldown == (Cooldown{}) || isAdmin(msg) {
+		return nil
+	}
+
+	keys := map[string]time.Duration{}
+	if command.Cooldown.User > 0 {
+		keys[cooldownKey(command.Name, "user", userKey(msg))] =
+			command.Cooldown.User
+	}
+	if command.Cooldown.Channel > 0 && strings.HasPrefix(msg.Where, "#") {
+		keys[cooldownKey(command.Name, "channel",
+			msg.Client.Name+" "+strings.ToLower(msg.Where))] =
+			command.Cooldown.Channel
+	}
+	if command.Cooldown.Global > 0 {
+		keys[cooldownKey(command.Name, "global", "")] = command.Cooldown.Global
+	}
+	return keys
+}
+
+// returns true if the command is still cooling down.
+// replies with the time left once per cooldown
+func checkCooldown(msg *irc.Message, command *Command) bool {
+	keys := cooldownKeys(msg, command)
+	if len(keys) == 0 {
+		return false
+	}
+
+	cooldownsMutex.Lock()
+	defer cooldownsMutex.Unlock()
+
+	now := time.Now()
+
+	// longest wait wins
+	var waiting *cooldownState
+	for key := range keys {
+		state, ok := cooldowns[key]
+		if !ok || now.After(state.until) {
+			continue
+		}
+		if waiting == nil || state.until.After(waiting.until) {
+			waiting = state
+		}
+	}
+
+	if waiting != nil {
+		if !waiting.warned {
+			waiting.warned = true
+			left := max(waiting.until.Sub(now).Round(time.Second), time.Second)
+			msg.Reply(fmt.Sprintf("slow down! try again in %s", left))
+		}
+		return true
+	}
+
+	return false
+}
+
+// once the handler actually runs
+func startCooldown(msg *irc.Message, command *Command) {
+	keys := cooldownKeys(msg, command)
+	if len(keys) == 0 {
+		return
+	}
+
+	cooldownsMutex.Lock()
+	defer cooldownsMutex.Unlock()
+
+	now := time.Now()
+	for key, duration := range keys {
+		cooldowns[key] = &cooldownState{until: now.Add(duration)}
+	}
+}
+
+func pruneCooldowns() {
+	cooldownsMutex.Lock()
+	defer cooldownsMutex.Unlock()
+
+	now := time.Now()
+	for key, state := range cooldowns {
+		if now.After(state.until) {
+			delete(cooldowns, key)
+		}
+	}
+}
+
+func init() {
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+			pruneCooldowns()
+		}
+	}()
+}
+
+type activeCooldown struct {
+	key  string
+	left time.Duration
+}
+
+// also forgets expired ones
+func activeCooldowns() []activeCooldown {
+	cooldownsMutex.Lock()
+	defer cooldownsMutex.Unlock()
+
+	now := time.Now()
+	out := []activeCooldown{}
+
+	for key, state := range cooldowns {
+		if now.After(state.until) {
+			delete(cooldowns, key)
+			continue
+		}
+		out = append(out, activeCooldown{key, state.until.Sub(now)})
+	}
+
+	slices.SortFunc(out, func(a, b activeCooldown) int {
+		return strings.Compare(a.key, b.key)
+	})
+
+	return out
+}
+
+// empty command clears all
+func clearCooldowns(command string) int {
+	cooldownsMutex.Lock()
+	defer cooldownsMutex.Unlock()
+
+	cleared := 0
+	for key := range cooldowns {
+		if command == "" || strings.HasPrefix(key, command+" ") {
+			delete(cooldowns, key)
+			cleared++
+		}
+	}
+	return cleared
+}
@@ -1,32 +1,44 @@
 package command
 
 import (
+	"context"
 	"net/http"
-	"slices"
+	"time"
 
 	"github.com/disintegration/imaging"
+	"github.com/makinori/mikogo/cmdmenu"
 	"github.com/makinori/mikogo/irc"
 	"github.com/makinori/mikogo/ircimage"
 )
 
-func handleFunImage(msg *irc.Message, args []string) {
-	if len(args) < 2 {
-		msg.Client.Send(msg.Where, "usage: [-nodither] <image url>")
+func funImage(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	// downloading and dithering can take a while
+	defer msg.Typing()()
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, args.URL("url").String(), nil,
+	)
+	if err != nil {
+		msg.Reply("failed to make request: " + err.Error())
 		return
 	}
 
-	noDither := slices.Contains(args, "-nodither")
-
-	res, err := http.Get(args[len(args)-1])
+	res, err := http.DefaultClient.Do(req)
 	if err != nil {
-		msg.Client.Send(msg.Where, "failed to get image: "+err.Error())
+		if jobStopped(ctx, msg) {
+			return
+		}
+		msg.Reply("failed to get image: " + err.Error())
 		return
 	}
 	defer res.Body.Close()
 
 	image, err := imaging.Decode(res.Body)
 	if err != nil {
-		msg.Client.Send(msg.Where, "failed to decode image: "+err.Error())
+		if jobStopped(ctx, msg) {
+			return
+		}
+		msg.Reply("failed to decode image: " + err.Error())
 		return
 	}
 
@@ -35,27 +47,41 @@ func handleFunImage(msg *irc.Message, args []string) {
 	img = imaging.AdjustContrast(img, 10)
 
 	var encodedImg ircimage.HalfBlockImage
-	if noDither {
+	if args.Flag("nodither") {
 		encodedImg, err = ircimage.ConvertImageWithColorCodesNodither(img)
 	} else {
 		encodedImg, err = ircimage.ConvertImageWithColorCodesDither(img, 32, 0.8)
 	}
 	if err != nil {
-		msg.Client.Send(msg.Where, "failed to convert image: "+err.Error())
+		msg.Reply("failed to convert image: " + err.Error())
 		return
 	}
 
-	msg.Client.Send(msg.Where, encodedImg.IRC())
+	msg.Reply(encodedImg.IRC())
 
 	// lines := strings.Split(encodedImg.IRC(), "\n")
 	// for i := range lines {
-	// 	msg.Client.Send(msg.Where, lines[i])
+	// 	msg.Reply(lines[i])
 	// }
 }
 
+var funImageCommand = cmdmenu.Command[irc.Message]{
+	Name: "image",
+	Examples: []string{
+		"image -nodither https://example.com/cat.png",
+	},
+	Params: []cmdmenu.Param{{Name: "url", Type: cmdmenu.URL}},
+	Flags:  []cmdmenu.Flag{{Name: "nodither"}},
+	Handle: funImage,
+}
+
 var CommandFunImage = Command{
 	Name:        "image",
 	Category:    "fun",
 	Description: "display image using formatting",
-	Handle:      handleFunImage,
+	Menu:        &funImageCommand,
+	Cooldown: Cooldown{
+		User:    time.Second * 30,
+		Channel: time.Second * 10,
+	},
 }
@@ -0,0 +1,135 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/makinori/mikogo/cmdmenu"
+	"github.com/makinori/mikogo/feed"
+	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/ircf"
+)
+
+// replies and returns false if not in a channel or not allowed
+func checkFeedManage(msg *irc.Message) bool {
+	if !strings.HasPrefix(msg.Where, "#") {
+		msg.Reply("run this in the channel")
+		return false
+	}
+	if !isAdmin(msg) {
+		msg.Reply("sorry only admins can manage feeds")
+		return false
+	}
+	return true
+}
+
+func feedAdd(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	if !checkFeedManage(msg) {
+		return
+	}
+
+	url := args.URL("url").String()
+
+	// still stops with the job
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+
+	title, err := feed.Subscribe(ctx, msg.Client.Name, msg.Where, url)
+	if err != nil {
+		if jobStopped(ctx, msg) {
+			return
+		}
+		msg.Reply("failed to subscribe: " + err.Error())
+		return
+	}
+
+	if title == "" {
+		title = url
+	}
+	msg.Reply("subscribed to " + ircf.Bold().Format(title) +
+		"! new entries will show up here")
+}
+
+func feedRemove(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	if !checkFeedManage(msg) {
+		return
+	}
+
+	err := feed.Unsubscribe(msg.Client.Name, msg.Where, args.String("url"))
+	if err != nil {
+		msg.Reply("failed to unsubscribe: " + err.Error())
+		return
+	}
+
+	msg.Ack("unsubscribed")
+}
+
+func feedList(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	if !strings.HasPrefix(msg.Where, "#") {
+		msg.Reply("run this in the channel")
+		return
+	}
+
+	feeds, err := feed.Subscriptions(msg.Client.Name, msg.Where)
+	if err != nil {
+		msg.Reply("failed to get feeds: " + err.Error())
+		return
+	}
+
+	if len(feeds) == 0 {
+		msg.Reply("no feeds in " + msg.Where)
+		return
+	}
+
+	out := ""
+	for _, url := range slices.Sorted(maps.Keys(feeds)) {
+		f := feeds[url]
+		if f.Title != "" {
+			out += ircf.Bold().Format(f.Title) + " "
+		}
+		out += url
+		if f.LastError != "" {
+			out += ircf.Color(98).Format(fmt.Sprintf(" (failing: %s)", f.LastError))
+		}
+		out += "\n"
+	}
+
+	msg.Reply(strings.TrimSpace(out))
+}
+
+var generalFeed = cmdmenu.Menu[irc.Message]{
+	Name: "feed",
+	Examples: []string{
+		"feed add https://blog.example.com/index.xml",
+	},
+	Commands: []cmdmenu.Runnable[irc.Message]{
+		&cmdmenu.Command[irc.Message]{
+			Name:        "add",
+			Description: "announce new entries here",
+			Params:      []cmdmenu.Param{{Name: "url", Type: cmdmenu.URL}},
+			Handle:      feedAdd,
+		},
+		&cmdmenu.Command[irc.Message]{
+			Name:        "remove",
+			Description: "stop announcing a feed here",
+			Params:      []cmdmenu.Param{{Name: "url"}},
+			Handle:      feedRemove,
+		},
+		&cmdmenu.Command[irc.Message]{
+			Name:        "list",
+			Description: "list feeds announced here",
+			Handle:      feedList,
+		},
+	},
+}
+
+var CommandGeneralFeed = Command{
+	Name:        "feed",
+	Category:    "general",
+	Description: "rss and atom feeds for this channel",
+	Menu:        &generalFeed,
+}
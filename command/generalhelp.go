@@ -1,6 +1,7 @@
 package command
 
 import (
+	"context"
 	"strings"
 
 	"github.com/elliotchance/orderedmap/v3"
@@ -8,13 +9,28 @@ import (
 	"github.com/makinori/mikogo/irc"
 )
 
-func handleGeneralHelp(msg *irc.Message, args []string) {
+// anything longer goes to a dm instead of flooding the channel
+const MAX_CHANNEL_HELP_LINES = 8
+
+func replyHelp(msg *irc.Message, out string) {
+	if !strings.HasPrefix(msg.Where, "#") ||
+		strings.Count(out, "\n") < MAX_CHANNEL_HELP_LINES {
+		msg.Reply(out)
+		return
+	}
+
+	msg.Client.Send(msg.Sender, out)
+	msg.Reply("sent you a dm")
+}
+
+func generalHelpList(msg *irc.Message) string {
 	categories := orderedmap.NewOrderedMap[string, []*Command]()
+	settings := messageChannel(msg)
 
 	for i := range commands {
 		command := commands[i]
 		_, canShow := canSenderRunCommand(msg, command)
-		if !canShow {
+		if !canShow || !commandEnabled(settings, command) {
 			continue
 		}
 		category, _ := categories.Get(command.Category)
@@ -35,12 +51,46 @@ func handleGeneralHelp(msg *irc.Message, args []string) {
 		}
 	}
 
-	msg.Client.Send(msg.Where, strings.TrimSpace(out))
+	return out + "type " + shownPrefix(msg) + "help <command> for more"
+}
+
+func generalHelpCommand(msg *irc.Message, path []string) string {
+	name := strings.ToLower(path[0])
+	settings := messageChannel(msg)
+
+	for _, command := range commands {
+		if command.Name != name {
+			continue
+		}
+		// dont let anyone know hidden commands exist
+		_, canShow := canSenderRunCommand(msg, command)
+		if !canShow || !commandEnabled(settings, command) {
+			break
+		}
+
+		entry, callStack, ok := command.Help().Find(path[1:])
+		if !ok {
+			return "no help for " + strings.Join(path, " ") +
+				". try " + callStack
+		}
+
+		return entry.Format(callStack, shownPrefix(msg))
+	}
+
+	return "no help for " + name
+}
+
+func handleGeneralHelp(ctx context.Context, msg *irc.Message, args []string) {
+	if len(args) > 1 {
+		replyHelp(msg, generalHelpCommand(msg, args[1:]))
+	} else {
+		replyHelp(msg, generalHelpList(msg))
+	}
 }
 
 var CommandGeneralHelp = Command{
 	Name:        "help",
 	Category:    "general",
-	Description: "show all commands",
+	Description: "show all commands or help for one",
 	Handle:      handleGeneralHelp,
 }
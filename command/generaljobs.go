@@ -0,0 +1,68 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/makinori/mikogo/cmdmenu"
+	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/ircf"
+)
+
+func handleGeneralJobs(ctx context.Context, msg *irc.Message, args []string) {
+	// owner sees everyone's
+	all := isOwner(msg.Client, msg.Sender)
+
+	out := ""
+	for _, j := range listJobs(msg, all) {
+		out += fmt.Sprintf("%s %s for %s",
+			ircf.BoldWhite.Format(fmt.Sprint(j.ID)), j.Line,
+			time.Since(j.Started).Round(time.Second),
+		)
+		if all {
+			out += fmt.Sprintf(" by %s in %s on %s", j.Sender, j.Where, j.Server)
+		}
+		out += "\n"
+	}
+
+	if out == "" {
+		msg.Reply("no jobs running")
+		return
+	}
+
+	msg.Reply(strings.TrimSpace(out))
+}
+
+func generalCancel(ctx context.Context, msg *irc.Message, args cmdmenu.Args) {
+	id := args.Int("id")
+
+	if !cancelJob(msg, id, isOwner(msg.Client, msg.Sender)) {
+		msg.Reply("no job of yours with that id")
+		return
+	}
+
+	msg.Ack(fmt.Sprintf("cancelled %d", id))
+}
+
+var generalCancelCommand = cmdmenu.Command[irc.Message]{
+	Name:   "cancel",
+	Params: []cmdmenu.Param{{Name: "id", Type: cmdmenu.Int}},
+	Handle: generalCancel,
+}
+
+var CommandGeneralJobs = Command{
+	Name:        "jobs",
+	Category:    "general",
+	Description: "list your running commands",
+	Handle:      handleGeneralJobs,
+}
+
+var CommandGeneralCancel = Command{
+	Name:        "cancel",
+	Category:    "general",
+	Description: "stop one of your running commands",
+	Exact:       true,
+	Menu:        &generalCancelCommand,
+}
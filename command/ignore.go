@@ -0,0 +1,189 @@
+package command
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/env"
+	"github.com/makinori/mikogo/irc"
+)
+
+const (
+	// more commands than this within the window gets you ignored
+	floodCommands = 5
+	floodWindow   = time.Second * 10
+	floodIgnore   = time.Minute * 5
+)
+
+var (
+	floodHistory      = map[string][]time.Time{}
+	floodHistoryMutex = sync.Mutex{}
+
+	// checked on every privmsg so kept in memory.
+	// nil until loaded or after changes
+	ignoreCache      map[string]db.Ignore
+	ignoreCacheMutex = sync.RWMutex{}
+)
+
+func isOwner(client *irc.Client, nick string) bool {
+	return client.Name == "home" && nick == env.OWNER
+}
+
+// case insensitive with * and ?
+func matchMask(pattern string, value string) bool {
+	expr := regexp.QuoteMeta(strings.ToLower(pattern))
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	matched, _ := regexp.MatchString("^"+expr+"$", strings.ToLower(value))
+	return matched
+}
+
+func matchIgnore(pattern string, mask string, account string) bool {
+	if accountPattern, ok := strings.CutPrefix(pattern, "$a:"); ok {
+		return account != "" && matchMask(accountPattern, account)
+	}
+	return matchMask(pattern, mask)
+}
+
+func getIgnores() map[string]db.Ignore {
+	ignoreCacheMutex.RLock()
+	cache := ignoreCache
+	ignoreCacheMutex.RUnlock()
+	if cache != nil {
+		return cache
+	}
+
+	ignoreCacheMutex.Lock()
+	defer ignoreCacheMutex.Unlock()
+	if ignoreCache != nil {
+		return ignoreCache
+	}
+
+	ignores, err := db.Ignores.GetAll()
+	if err != nil {
+		slog.Error("failed to get ignores", "err", err)
+		return nil
+	}
+
+	ignoreCache = map[string]db.Ignore{}
+	for pattern, ignore := range ignores.AllFromFront() {
+		ignoreCache[pattern] = ignore
+	}
+	return ignoreCache
+}
+
+func invalidateIgnores() {
+	ignoreCacheMutex.Lock()
+	defer ignoreCacheMutex.Unlock()
+	ignoreCache = nil
+}
+
+func putIgnore(pattern string, ignore db.Ignore) error {
+	defer invalidateIgnores()
+	return db.Ignores.Put(pattern, ignore)
+}
+
+func removeIgnore(pattern string) error {
+	defer invalidateIgnores()
+	return db.Ignores.Delete(pattern)
+}
+
+func pruneIgnores() {
+	for pattern, ignore := range getIgnores() {
+		if !ignore.Expired() {
+			continue
+		}
+		err := removeIgnore(pattern)
+		if err != nil {
+			slog.Error("failed to remove expired ignore", "err", err)
+		}
+	}
+}
+
+func init() {
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+			pruneIgnores()
+		}
+	}()
+}
+
+func isIgnored(mask string, account string) bool {
+	for pattern, ignore := range getIgnores() {
+		if !ignore.Expired() && matchIgnore(pattern, mask, account) {
+			return true
+		}
+	}
+	return false
+}
+
+// drops messages from ignored users before they're dispatched
+var IgnoreMiddleware = irc.Middleware{
+	Name: "ignore",
+	Inbound: func(c *irc.Client, line *irc.Line) bool {
+		if line.Command != "PRIVMSG" || isOwner(c, line.Nick()) {
+			return true
+		}
+		return !isIgnored(line.Source, line.Tags["account"])
+	},
+}
+
+// returns true if the sender just got ignored for flooding
+func checkFlood(msg *irc.Message) bool {
+	if isOwner(msg.Client, msg.Sender) {
+		return false
+	}
+
+	// not the whole host, as that could be a shared bouncer
+	pattern := "$a:" + msg.Account
+	if msg.Account == "" {
+		_, userHost, _ := strings.Cut(msg.Mask, "!")
+		pattern = "*!" + userHost
+	}
+
+	floodHistoryMutex.Lock()
+	now := time.Now()
+	history := floodHistory[pattern]
+	i := 0
+	for _, t := range history {
+		if now.Sub(t) < floodWindow {
+			history[i] = t
+			i++
+		}
+	}
+	history = append(history[:i], now)
+	flooding := len(history) > floodCommands
+	if flooding {
+		delete(floodHistory, pattern)
+	} else {
+		floodHistory[pattern] = history
+	}
+	floodHistoryMutex.Unlock()
+
+	if !flooding {
+		return false
+	}
+
+	err := putIgnore(strings.ToLower(pattern), db.Ignore{
+		Reason:  "flooding commands",
+		Added:   now,
+		Expires: now.Add(floodIgnore),
+		Auto:    true,
+	})
+	if err != nil {
+		slog.Error("failed to add ignore", "err", err)
+	}
+
+	msg.Reply(fmt.Sprintf("slow down! ignoring you for %s", floodIgnore))
+	irc.ReportIncident(irc.SeverityInfo, msg.Client.Name, fmt.Sprintf(
+		"ignored %s for %s for flooding commands", pattern, floodIgnore,
+	))
+
+	return true
+}
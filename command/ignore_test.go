@@ -0,0 +1,69 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/makinori/mikogo/db"
+)
+
+func TestMatchIgnore(t *testing.T) {
+	for _, c := range []struct {
+		pattern string
+		mask    string
+		account string
+		match   bool
+	}{
+		{"*!*@bad.host", "alice!a@bad.host", "", true},
+		{"*!*@bad.host", "alice!a@good.host", "", false},
+		{"Alice!*@*", "alice!a@good.host", "", true},
+		{"al?ce!*@*", "alice!a@good.host", "", true},
+		{"a.ice!*@*", "alice!a@good.host", "", false},
+		{"$a:alice", "whoever!a@good.host", "alice", true},
+		{"$a:alice", "alice!a@good.host", "", false},
+	} {
+		if matchIgnore(c.pattern, c.mask, c.account) != c.match {
+			t.Errorf("%s on %s (%s) should be %t",
+				c.pattern, c.mask, c.account, c.match,
+			)
+		}
+	}
+}
+
+func TestFloodIgnore(t *testing.T) {
+	conn := connectTestServer(t, "flood", "#test")
+	t.Cleanup(func() {
+		removeIgnore("*!user@irctest.host")
+	})
+
+	for range floodCommands + 1 {
+		conn.Privmsg("", "flooder", "#test", "m!nope")
+	}
+	conn.Expect(t, `PRIVMSG #test :slow down! ignoring you`)
+
+	_, err, exists := db.Ignores.Get("*!user@irctest.host")
+	if err != nil || !exists {
+		t.Fatalf("expected ignore to be added: %v", err)
+	}
+
+	if !isIgnored("flooder!user@irctest.host", "") {
+		t.Fatal("expected flooder to be ignored")
+	}
+	if isIgnored("someone!other@irctest.host", "") {
+		t.Fatal("expected others on the same host not to be ignored")
+	}
+
+	// accounts are ignored rather than masks
+	removeIgnore("*!user@irctest.host")
+	t.Cleanup(func() {
+		removeIgnore("$a:flooder")
+	})
+
+	for range floodCommands + 1 {
+		conn.Privmsg("account=flooder", "renamed", "#test", "m!nope")
+	}
+	conn.Expect(t, `PRIVMSG #test :slow down! ignoring you`)
+
+	if !isIgnored("whoever!else@elsewhere", "flooder") {
+		t.Fatal("expected flooder's account to be ignored")
+	}
+}
@@ -0,0 +1,125 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/makinori/mikogo/irc"
+)
+
+var errJobCancelled = errors.New("cancelled")
+
+// a running command
+type job struct {
+	ID      int
+	Command string
+	Line    string
+	Server  string
+	Sender  string
+	Where   string
+	User    string
+	Started time.Time
+
+	msg    *irc.Message
+	cancel context.CancelCauseFunc
+}
+
+var (
+	jobs      = map[int]*job{}
+	jobsMutex = sync.Mutex{}
+	lastJobID = 0
+)
+
+// account if logged in, otherwise the whole mask as hosts can be shared
+func jobOwner(msg *irc.Message) string {
+	if msg.Account != "" {
+		return msg.Client.Name + " $a:" + strings.ToLower(msg.Account)
+	}
+	return msg.Client.Name + " " + strings.ToLower(msg.Mask)
+}
+
+// done has to be called once the handler returns
+func startJob(
+	msg *irc.Message, command *Command, args []string,
+) (context.Context, func()) {
+	ctx, cancelCause := context.WithCancelCause(context.Background())
+	ctx, cancelTimeout := context.WithTimeout(ctx, command.timeout())
+
+	jobsMutex.Lock()
+	lastJobID++
+	j := &job{
+		ID:      lastJobID,
+		Command: command.Name,
+		Line:    strings.Join(args, " "),
+		Server:  msg.Client.Name,
+		Sender:  msg.Sender,
+		Where:   msg.Where,
+		User:    jobOwner(msg),
+		Started: time.Now(),
+		msg:     msg,
+		cancel:  cancelCause,
+	}
+	jobs[j.ID] = j
+	jobsMutex.Unlock()
+
+	return ctx, func() {
+		cancelTimeout()
+		cancelCause(nil)
+
+		jobsMutex.Lock()
+		delete(jobs, j.ID)
+		jobsMutex.Unlock()
+	}
+}
+
+// sorted by id. everyone's if all
+func listJobs(msg *irc.Message, all bool) []*job {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	user := jobOwner(msg)
+	out := []*job{}
+	for _, j := range jobs {
+		// the jobs command itself
+		if j.msg == msg {
+			continue
+		}
+		if all || j.User == user {
+			out = append(out, j)
+		}
+	}
+
+	slices.SortFunc(out, func(a, b *job) int {
+		return a.ID - b.ID
+	})
+
+	return out
+}
+
+// returns false if not found or not allowed
+func cancelJob(msg *irc.Message, id int, anyone bool) bool {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	j, ok := jobs[id]
+	if !ok || !anyone && j.User != jobOwner(msg) {
+		return false
+	}
+
+	j.cancel(fmt.Errorf("%w by %s", errJobCancelled, msg.Sender))
+	return true
+}
+
+// replies with why if the job was cancelled or timed out
+func jobStopped(ctx context.Context, msg *irc.Message) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	msg.Reply("stopped: " + context.Cause(ctx).Error())
+	return true
+}
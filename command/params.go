@@ -0,0 +1,83 @@
+package command
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/makinori/mikogo/cmdmenu"
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/manage"
+)
+
+// cmdmenu types that need to know about the bot
+
+// an existing server name
+var paramServer = &cmdmenu.Type{
+	Name: "server",
+	Parse: func(value string) (any, error) {
+		_, err, exists := db.Servers.Get(value)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, errors.New("server not found")
+		}
+		return value, nil
+	},
+}
+
+// adds # if missing
+var paramChannel = &cmdmenu.Type{
+	Name: "channel",
+	Parse: func(value string) (any, error) {
+		channel := manage.NormalizeChannel(value)
+		if channel == "#" || strings.ContainsAny(channel, " ,\x07") {
+			return nil, errors.New("not a channel name")
+		}
+		return channel, nil
+	},
+}
+
+// as shown in incidents, # is optional
+var paramIncidentID = &cmdmenu.Type{
+	Name: "incident id",
+	Parse: func(value string) (any, error) {
+		id, err := strconv.ParseUint(strings.TrimPrefix(value, "#"), 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid incident id")
+		}
+		return id, nil
+	},
+}
+
+var paramPrefix = &cmdmenu.Type{
+	Name: "prefix",
+	Parse: func(value string) (any, error) {
+		return value, validatePrefix(value)
+	},
+}
+
+var paramCommandOrCategory = &cmdmenu.Type{
+	Name: "command or category",
+	Parse: func(value string) (any, error) {
+		value = strings.ToLower(value)
+		if !isCommandOrCategory(value) {
+			return nil, errors.New("no command or category called " + value)
+		}
+		return value, nil
+	},
+}
+
+var paramOnOff = &cmdmenu.Type{
+	Name: "on or off",
+	Parse: func(value string) (any, error) {
+		switch strings.ToLower(value) {
+		case "on":
+			return true, nil
+		case "off":
+			return false, nil
+		}
+		return nil, errors.New("should be on or off")
+	},
+}
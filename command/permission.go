@@ -0,0 +1,46 @@
+package command
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/makinori/mikogo/env"
+	"github.com/makinori/mikogo/irc"
+)
+
+// server name to accounts
+var admins = parseAdmins(env.ADMINS)
+
+func parseAdmins(value string) map[string][]string {
+	out := map[string][]string{}
+	for entry := range strings.SplitSeq(value, ",") {
+		server, account, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || server == "" || account == "" {
+			continue
+		}
+		out[server] = append(out[server], account)
+	}
+	return out
+}
+
+// owner or logged into an admin account
+func isAdmin(msg *irc.Message) bool {
+	if isOwner(msg.Client, msg.Sender) {
+		return true
+	}
+	if msg.Account == "" {
+		return false
+	}
+	return slices.ContainsFunc(admins[msg.Client.Name], func(account string) bool {
+		return strings.EqualFold(account, msg.Account)
+	})
+}
+
+// account if logged in, otherwise host like flood protection
+func userKey(msg *irc.Message) string {
+	if msg.Account != "" {
+		return msg.Client.Name + " $a:" + strings.ToLower(msg.Account)
+	}
+	_, host, _ := strings.Cut(msg.Mask, "@")
+	return msg.Client.Name + " *!*@" + host
+}
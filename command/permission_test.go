@@ -0,0 +1,33 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/makinori/mikogo/env"
+	"github.com/makinori/mikogo/irc"
+)
+
+func TestIsAdmin(t *testing.T) {
+	previous := admins
+	admins = parseAdmins("libera:Alice, home:bob,invalid")
+	defer func() { admins = previous }()
+
+	home := &irc.Client{Name: "home"}
+	libera := &irc.Client{Name: "libera"}
+
+	for _, test := range []struct {
+		msg      irc.Message
+		expected bool
+	}{
+		{irc.Message{Client: home, Sender: env.OWNER}, true},
+		{irc.Message{Client: libera, Sender: env.OWNER}, false},
+		{irc.Message{Client: libera, Sender: "x", Account: "alice"}, true},
+		{irc.Message{Client: home, Sender: "alice", Account: ""}, false},
+		{irc.Message{Client: home, Sender: "x", Account: "alice"}, false},
+		{irc.Message{Client: home, Sender: "x", Account: "bob"}, true},
+	} {
+		if isAdmin(&test.msg) != test.expected {
+			t.Errorf("expected %t for %+v", test.expected, test.msg)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package command
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/irc"
+)
+
+const (
+	defaultPrefix = "m!"
+
+	MAX_PREFIX_LENGTH = 8
+)
+
+// channel overrides server which overrides the default
+func getPrefix(server string, channel db.Channel) string {
+	if channel.Prefix != "" {
+		return channel.Prefix
+	}
+
+	settings, err, _ := db.Servers.Get(server)
+	if err != nil {
+		slog.Error("failed to get server", "err", err)
+	}
+	if settings.Prefix != "" {
+		return settings.Prefix
+	}
+
+	return defaultPrefix
+}
+
+// for printing usage. direct messages dont need one
+func shownPrefix(msg *irc.Message) string {
+	if !strings.HasPrefix(msg.Where, "#") {
+		return ""
+	}
+	return getPrefix(msg.Client.Name, messageChannel(msg))
+}
+
+// returns the command line if the message was for us.
+// channels need the prefix or our nick like "mikogo: help"
+func trimPrefix(msg *irc.Message, prefix string) (string, bool) {
+	line := strings.TrimSpace(msg.Message)
+
+	if strings.HasPrefix(line, prefix) {
+		return strings.TrimPrefix(line, prefix), true
+	}
+
+	nick := msg.Client.Nick()
+	if len(line) > len(nick) &&
+		strings.EqualFold(line[:len(nick)], nick) &&
+		strings.ContainsRune(":,", rune(line[len(nick)])) {
+		return strings.TrimSpace(line[len(nick)+1:]), true
+	}
+
+	return line, !strings.HasPrefix(msg.Where, "#")
+}
+
+func validatePrefix(prefix string) error {
+	if len(prefix) > MAX_PREFIX_LENGTH {
+		return errors.New("prefix is too long")
+	}
+	if strings.ContainsAny(prefix, " \t") {
+		return errors.New("prefix can't have spaces")
+	}
+	return nil
+}
+
+// replaces the first argument if its an alias. commands take priority
+func expandAlias(args []string, channel db.Channel) []string {
+	name := strings.ToLower(args[0])
+	if findCommandByName(name) != nil {
+		return args
+	}
+
+	alias, ok := channel.Aliases[name]
+	if !ok {
+		return args
+	}
+
+	return append(whiteSpaceRegexp.Split(alias, -1), args[1:]...)
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+type AuditEntry struct {
+	Time time.Time
+	// where the command was sent from
+	Server string
+	Sender string
+	Action string
+	Detail string
+}
+
+var Audit = cborCrud[AuditEntry]{
+	bucket: "audit",
+}
+
+// keyed by time so entries stay in order
+func AddAudit(entry AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	return Audit.Add(fmt.Sprintf("%020d", entry.Time.UnixNano()), entry)
+}
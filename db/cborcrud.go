@@ -2,16 +2,69 @@ package db
 
 import (
 	"errors"
+	"reflect"
 
 	"github.com/elliotchance/orderedmap/v3"
 	"github.com/fxamacker/cbor/v2"
 	"go.etcd.io/bbolt"
 )
 
+var (
+	ErrExists   = errors.New("already exists")
+	ErrNotFound = errors.New("not found")
+)
+
 type cborCrud[T any] struct {
 	bucket string
 }
 
+func toarrayLen(t reflect.Type) int {
+	n := 0
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if field.IsExported() && field.Tag.Get("cbor") != "-" {
+			n++
+		}
+	}
+	return n
+}
+
+// toarray structs fail to decode if fields were added since they were stored,
+// so pad older arrays with nulls which decode as zero values
+func unmarshal[T any](data []byte, value *T) error {
+	err := cbor.Unmarshal(data, value)
+
+	var typeErr *cbor.UnmarshalTypeError
+	if err == nil || !errors.As(err, &typeErr) {
+		return err
+	}
+
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct {
+		return err
+	}
+
+	var fields []cbor.RawMessage
+	if cbor.Unmarshal(data, &fields) != nil {
+		return err
+	}
+
+	n := toarrayLen(t)
+	if len(fields) >= n {
+		return err
+	}
+	for len(fields) < n {
+		fields = append(fields, cbor.RawMessage{0xf6}) // null
+	}
+
+	padded, paddedErr := cbor.Marshal(fields)
+	if paddedErr != nil {
+		return err
+	}
+
+	return cbor.Unmarshal(padded, value)
+}
+
 func (c *cborCrud[T]) GetAll() (*orderedmap.OrderedMap[string, T], error) {
 	all := orderedmap.NewOrderedMap[string, T]()
 
@@ -23,7 +76,7 @@ func (c *cborCrud[T]) GetAll() (*orderedmap.OrderedMap[string, T], error) {
 
 		return bucket.ForEach(func(key, data []byte) error {
 			var value T
-			err := cbor.Unmarshal(data, &value)
+			err := unmarshal(data, &value)
 			if err != nil {
 				return err
 			}
@@ -52,7 +105,7 @@ func (c *cborCrud[T]) Get(key string) (value T, err error, exists bool) {
 		}
 
 		exists = true
-		return cbor.Unmarshal(data, &value)
+		return unmarshal(data, &value)
 	})
 	return
 }
@@ -73,6 +126,52 @@ func (c *cborCrud[T]) Put(key string, value T) error {
 	})
 }
 
+// read, modify and write in one transaction so concurrent puts arent lost.
+// an error from update aborts without writing
+func (c *cborCrud[T]) Update(key string, update func(value *T) error) error {
+	return c.update(key, false, update)
+}
+
+// like update but starts from the zero value if missing
+func (c *cborCrud[T]) Upsert(key string, update func(value *T) error) error {
+	return c.update(key, true, update)
+}
+
+func (c *cborCrud[T]) update(
+	key string, create bool, update func(value *T) error,
+) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(c.bucket))
+		if bucket == nil {
+			return errors.New(c.bucket + " bucket not found")
+		}
+
+		var value T
+
+		data := bucket.Get([]byte(key))
+		if len(data) > 0 {
+			err := unmarshal(data, &value)
+			if err != nil {
+				return err
+			}
+		} else if !create {
+			return ErrNotFound
+		}
+
+		err := update(&value)
+		if err != nil {
+			return err
+		}
+
+		data, err = cbor.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(key), data)
+	})
+}
+
 func (c *cborCrud[T]) Add(key string, value T) error {
 	data, err := cbor.Marshal(value)
 	if err != nil {
@@ -86,7 +185,7 @@ func (c *cborCrud[T]) Add(key string, value T) error {
 		}
 
 		if len(bucket.Get([]byte(key))) > 0 {
-			return errors.New("already exists")
+			return ErrExists
 		}
 
 		return bucket.Put([]byte(key), data)
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"strings"
+)
+
+// not toarray so fields can be added without breaking older entries
+type Channel struct {
+	// bots that are allowed to run commands
+	TrustedBots []string
+	// command prefix. empty uses the server's
+	Prefix string
+	// name to command line, e.g. img to image -nodither
+	Aliases map[string]string
+	// commands or categories that wont run here
+	Disabled []string
+	// if not empty, only these commands or categories run here
+	Allowed []string
+	// dont reply to unknown commands
+	Quiet bool
+}
+
+var Channels = cborCrud[Channel]{
+	bucket: "channels",
+}
+
+func ChannelKey(server string, channel string) string {
+	return server + " " + strings.ToLower(channel)
+}
+
+// returns defaults if not found
+func GetChannel(server string, channel string) (Channel, error) {
+	settings, err, _ := Channels.Get(ChannelKey(server, channel))
+	return settings, err
+}
+
+func PutChannel(server string, channel string, settings Channel) error {
+	return Channels.Put(ChannelKey(server, channel), settings)
+}
+
+// starts from defaults if not found
+func UpdateChannel(
+	server string, channel string, update func(settings *Channel) error,
+) error {
+	return Channels.Upsert(ChannelKey(server, channel), update)
+}
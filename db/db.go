@@ -1,6 +1,10 @@
 package db
 
 import (
+	"errors"
+	"strings"
+	"time"
+
 	"github.com/makinori/mikogo/env"
 	"go.etcd.io/bbolt"
 )
@@ -11,26 +15,53 @@ var (
 
 func Init() error {
 	var err error
-	db, err = bbolt.Open("data.db", 0644, &bbolt.Options{})
+	db, err = bbolt.Open(env.DB_PATH, 0644, &bbolt.Options{})
 	if err != nil {
 		return err
 	}
 
-	db.Update(func(tx *bbolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(Servers.bucket))
-		if err != nil {
-			return err
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range []string{
+			Servers.bucket,
+			Audit.bucket,
+			outboxBucket,
+			Incidents.bucket,
+			Ignores.bucket,
+			Channels.bucket,
+			Stats.bucket,
+			healthBucket,
+			Hooks.bucket,
+			Feeds.bucket,
+		} {
+			_, err := tx.CreateBucketIfNotExists([]byte(bucket))
+			if err != nil {
+				return err
+			}
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
 
-	// ensure home server exists and has address set to env.
-	// should never be deleted or have its address modified.
+	err = migrateServerAddresses()
+	if err != nil {
+		return err
+	}
+
+	// ensure home server exists and has addresses set to env.
+	// should never be deleted or have its addresses modified.
 	homeServer, err, _ := Servers.Get("home")
 	if err != nil {
 		return err
 	}
-	homeServer.Address = env.HOME_SERVER
+	homeServer.Addresses = nil
+	for address := range strings.SplitSeq(env.HOME_SERVER, ",") {
+		address = strings.TrimSpace(address)
+		if address != "" {
+			homeServer.Addresses = append(homeServer.Addresses, address)
+		}
+	}
 	err = Servers.Put("home", homeServer)
 	if err != nil {
 		return err
@@ -38,3 +69,22 @@ func Init() error {
 
 	return nil
 }
+
+const healthBucket = "health"
+
+// writes the current time so a full disk or bad permissions show up
+func CheckWritable() error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(healthBucket))
+		if bucket == nil {
+			return errors.New(healthBucket + " bucket not found")
+		}
+		return bucket.Put(
+			[]byte("checked"), []byte(time.Now().Format(time.RFC3339Nano)),
+		)
+	})
+}
+
+func Close() error {
+	return db.Close()
+}
@@ -0,0 +1,24 @@
+package db
+
+import (
+	"time"
+)
+
+type FeedSubscription struct {
+	Server  string
+	Channel string
+}
+
+// keyed by url
+type Feed struct {
+	Title         string
+	Subscriptions []FeedSubscription
+	// ids of entries already announced
+	Seen       []string
+	LastPolled time.Time
+	LastError  string
+}
+
+var Feeds = cborCrud[Feed]{
+	bucket: "feeds",
+}
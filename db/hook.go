@@ -0,0 +1,15 @@
+package db
+
+// inbound webhooks keyed by name, which is also the url path
+type Hook struct {
+	Server  string
+	Channel string
+	// hmac key for forges or bearer token for everything else
+	Secret string
+	// how the payload is turned into messages. see webhook package
+	Template string
+}
+
+var Hooks = cborCrud[Hook]{
+	bucket: "hooks",
+}
@@ -0,0 +1,23 @@
+package db
+
+import (
+	"time"
+)
+
+// keyed by lower case mask. nick!user@host with wildcards or $a:account
+type Ignore struct {
+	Reason string
+	Added  time.Time
+	// zero never expires
+	Expires time.Time
+	// added by flood protection
+	Auto bool
+}
+
+func (i *Ignore) Expired() bool {
+	return !i.Expires.IsZero() && time.Now().After(i.Expires)
+}
+
+var Ignores = cborCrud[Ignore]{
+	bucket: "ignores",
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"go.etcd.io/bbolt"
+)
+
+type Incident struct {
+	Severity uint8
+	Server   string
+	Message  string
+	First    time.Time
+	Last     time.Time
+	// how many times it was reported whilst deduplicated
+	Count         int
+	Acked         bool
+	LastDelivered time.Time
+}
+
+var Incidents = cborCrud[Incident]{
+	bucket: "incidents",
+}
+
+// ids are sequential and padded so they stay in order
+func IncidentKey(id uint64) string {
+	return fmt.Sprintf("%08d", id)
+}
+
+func AddIncident(incident Incident) (id uint64, err error) {
+	data, err := cbor.Marshal(incident)
+	if err != nil {
+		return 0, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(Incidents.bucket))
+		if bucket == nil {
+			return errors.New(Incidents.bucket + " bucket not found")
+		}
+
+		id, err = bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(IncidentKey(id)), data)
+	})
+
+	return
+}
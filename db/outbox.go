@@ -0,0 +1,156 @@
+package db
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"go.etcd.io/bbolt"
+)
+
+// messages waiting for a server to connect.
+// each server gets its own nested bucket keyed by sequence so order is kept
+
+const outboxBucket = "outbox"
+
+type OutboxEntry struct {
+	Tags    map[string]string
+	To      string
+	Message string
+	// zero never expires
+	Expires time.Time
+}
+
+func (e *OutboxEntry) Expired() bool {
+	return !e.Expires.IsZero() && time.Now().After(e.Expires)
+}
+
+func PushOutbox(server string, entry OutboxEntry) error {
+	data, err := cbor.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		outbox := tx.Bucket([]byte(outboxBucket))
+		if outbox == nil {
+			return errors.New(outboxBucket + " bucket not found")
+		}
+
+		bucket, err := outbox.CreateBucketIfNotExists([]byte(server))
+		if err != nil {
+			return err
+		}
+
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(binary.BigEndian.AppendUint64(nil, seq), data)
+	})
+}
+
+// send is called in order and should return false to stop flushing.
+// entries are removed once sent or expired
+func FlushOutbox(
+	server string, send func(entry OutboxEntry) bool,
+) (sent int, expired int, err error) {
+	type keyedEntry struct {
+		key   []byte
+		entry OutboxEntry
+	}
+
+	var entries []keyedEntry
+
+	err = db.View(func(tx *bbolt.Tx) error {
+		outbox := tx.Bucket([]byte(outboxBucket))
+		if outbox == nil {
+			return errors.New(outboxBucket + " bucket not found")
+		}
+
+		bucket := outbox.Bucket([]byte(server))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(key, data []byte) error {
+			var entry OutboxEntry
+			err := cbor.Unmarshal(data, &entry)
+			if err != nil {
+				return err
+			}
+			// key is only valid during the transaction
+			entries = append(entries, keyedEntry{
+				key: append([]byte{}, key...), entry: entry,
+			})
+			return nil
+		})
+	})
+	if err != nil || len(entries) == 0 {
+		return
+	}
+
+	var done [][]byte
+
+	for _, e := range entries {
+		if e.entry.Expired() {
+			expired++
+			done = append(done, e.key)
+			continue
+		}
+		if !send(e.entry) {
+			break
+		}
+		sent++
+		done = append(done, e.key)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(outboxBucket)).Bucket([]byte(server))
+		if bucket == nil {
+			return nil
+		}
+		for _, key := range done {
+			err := bucket.Delete(key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return
+}
+
+func DeleteOutbox(server string) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		outbox := tx.Bucket([]byte(outboxBucket))
+		if outbox == nil {
+			return errors.New(outboxBucket + " bucket not found")
+		}
+		err := outbox.DeleteBucket([]byte(server))
+		if errors.Is(err, bbolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// how many entries are waiting, including expired ones not flushed yet
+func OutboxLen(server string) (int, error) {
+	n := 0
+	err := db.View(func(tx *bbolt.Tx) error {
+		outbox := tx.Bucket([]byte(outboxBucket))
+		if outbox == nil {
+			return errors.New(outboxBucket + " bucket not found")
+		}
+		bucket := outbox.Bucket([]byte(server))
+		if bucket != nil {
+			n = bucket.Stats().KeyN
+		}
+		return nil
+	})
+	return n, err
+}
@@ -1,15 +1,34 @@
 package db
 
+import (
+	"slices"
+)
+
 type Server struct {
-	_        struct{} `cbor:",toarray"`
+	_ struct{} `cbor:",toarray"`
+	// legacy single address. moved into Addresses on init
 	Address  string
 	Channels []string
+	// identity overrides. empty uses the default
+	Nick     string
+	Ident    string
+	Realname string
+	Pass     string
+	// user modes set after connecting
+	Modes string
+	// tried in order when failing to connect
+	Addresses []string
+	// command prefix. empty uses the default
+	Prefix string
+	// last address that got through registration, tried first on start
+	LastAddress string
 }
 
 var Servers = cborCrud[Server]{
 	bucket: "servers",
 }
 
+// matches any of the server's addresses
 func GetServerByAddress(address string) (string, Server, error, bool) {
 	servers, err := Servers.GetAll()
 	if err != nil {
@@ -17,10 +36,37 @@ func GetServerByAddress(address string) (string, Server, error, bool) {
 	}
 
 	for name, server := range servers.AllFromBack() {
-		if server.Address == address {
+		if slices.Contains(server.Addresses, address) {
 			return name, server, nil, true
 		}
 	}
 
 	return "", Server{}, nil, false
 }
+
+func migrateServerAddresses() error {
+	servers, err := Servers.GetAll()
+	if err != nil {
+		return err
+	}
+
+	for name, server := range servers.AllFromFront() {
+		if server.Address == "" {
+			continue
+		}
+
+		if !slices.Contains(server.Addresses, server.Address) {
+			server.Addresses = append(
+				[]string{server.Address}, server.Addresses...,
+			)
+		}
+		server.Address = ""
+
+		err = Servers.Put(name, server)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package db
+
+import (
+	"time"
+)
+
+// totals per server, only stored if enabled in env
+type ServerStats struct {
+	// when counting started
+	Since      time.Time
+	Connects   uint64
+	Reconnects uint64
+	LinesIn    uint64
+	LinesOut   uint64
+	BytesIn    uint64
+	BytesOut   uint64
+	Commands   uint64
+}
+
+var Stats = cborCrud[ServerStats]{
+	bucket: "stats",
+}
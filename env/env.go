@@ -4,7 +4,9 @@ import (
 	"log/slog"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 )
@@ -12,14 +14,43 @@ import (
 var (
 	_, DEV = os.LookupEnv("DEV")
 
+	// keep connection stats totals in the db across restarts
+	_, PERSIST_STATS = os.LookupEnv("PERSIST_STATS")
+
 	NICK = getEnv("NICK", "mikogo")
 
+	DB_PATH = getEnv("DB_PATH", "data.db")
+
+	// where admin server trace writes to
+	TRACE_DIR = getEnv("TRACE_DIR", ".")
+
 	// only listen to command from this nick on home server
 	OWNER = getEnv("OWNER", "maki")
 
 	// it will always be connected here
+	// comma separated for failover
 	HOME_SERVER = getEnv("HOME_SERVER", "127.0.0.1:6697")
 
+	// how many messages can be handled at once
+	WORKERS = getEnvInt("WORKERS", 4)
+
+	// where incidents get sent. server:target[:min severity] comma separated.
+	// defaults to the owner on home
+	INCIDENT_TARGETS = getEnv("INCIDENT_TARGETS", "")
+
+	// listen address for /metrics and such, e.g. :8080. empty disables
+	HTTP_ADDRESS = getEnv("HTTP_ADDRESS", "")
+
+	// bearer token for the http api. empty disables it
+	API_TOKEN = getEnv("API_TOKEN", "")
+
+	// server:account comma separated. can manage feeds and such.
+	// accounts as nicks can be taken
+	ADMINS = getEnv("ADMINS", "")
+
+	// how often rss and atom feeds are checked
+	FEED_INTERVAL = getEnvDuration("FEED_INTERVAL", time.Minute*15)
+
 	// injected at build
 	GIT_COMMIT string
 )
@@ -32,6 +63,32 @@ func getEnv(key string, fallback string) string {
 	return fallback
 }
 
+func getEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("invalid number in env", "key", key, "value", value)
+		return fallback
+	}
+	return n
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("invalid duration in env", "key", key, "value", value)
+		return fallback
+	}
+	return d
+}
+
 func GetGoVersion() string {
 	return strings.TrimPrefix(
 		strings.SplitN(runtime.Version(), " ", 2)[0], "go",
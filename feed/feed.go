@@ -0,0 +1,287 @@
+// polls rss and atom feeds and announces new entries to channels
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/env"
+	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/ircf"
+)
+
+const (
+	FETCH_TIMEOUT = time.Second * 30
+	MAX_BODY      = 5 << 20
+	// per feed per poll so a broken feed cant flood a channel
+	MAX_ANNOUNCE = 5
+	// seen ids kept beyond whats in the feed right now
+	MAX_SEEN = 500
+)
+
+var (
+	// read-modify-write of feeds in the db
+	feedsMutex = sync.Mutex{}
+
+	httpClient = &http.Client{Timeout: FETCH_TIMEOUT}
+
+	// var so tests can capture messages
+	send = func(server string, channel string, msg string) {
+		client := irc.GetClient(server)
+		if client == nil {
+			slog.Warn("feed subscriber server not found", "server", server)
+			return
+		}
+		client.Send(channel, msg)
+	}
+)
+
+func Fetch(ctx context.Context, url string) (*Parsed, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "mikogo")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got %s", res.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, MAX_BODY))
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+func entryIDs(parsed *Parsed) []string {
+	ids := make([]string, 0, len(parsed.Entries))
+	for _, entry := range parsed.Entries {
+		ids = append(ids, entry.ID)
+	}
+	return ids
+}
+
+func sameSubscription(server string, channel string) func(db.FeedSubscription) bool {
+	return func(sub db.FeedSubscription) bool {
+		return sub.Server == server && strings.EqualFold(sub.Channel, channel)
+	}
+}
+
+// fetches first so bad urls are caught and existing entries aren't announced.
+// returns the feed title
+func Subscribe(
+	ctx context.Context, server string, channel string, url string,
+) (string, error) {
+	feed, err, exists := db.Feeds.Get(url)
+	if err != nil {
+		return "", err
+	}
+
+	if slices.ContainsFunc(feed.Subscriptions, sameSubscription(server, channel)) {
+		return "", errors.New("already subscribed")
+	}
+
+	// before locking as it can take a while
+	var parsed *Parsed
+	if !exists {
+		parsed, err = Fetch(ctx, url)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	feedsMutex.Lock()
+	defer feedsMutex.Unlock()
+
+	// could have changed whilst fetching
+	feed, err, exists = db.Feeds.Get(url)
+	if err != nil {
+		return "", err
+	}
+
+	if slices.ContainsFunc(feed.Subscriptions, sameSubscription(server, channel)) {
+		return "", errors.New("already subscribed")
+	}
+
+	if !exists {
+		if parsed == nil {
+			return "", errors.New("feed was removed whilst subscribing. try again")
+		}
+		feed.Title = parsed.Title
+		feed.Seen = entryIDs(parsed)
+		feed.LastPolled = time.Now()
+	}
+
+	feed.Subscriptions = append(feed.Subscriptions, db.FeedSubscription{
+		Server: server, Channel: channel,
+	})
+
+	return feed.Title, db.Feeds.Put(url, feed)
+}
+
+// forgets the feed once nobody is subscribed
+func Unsubscribe(server string, channel string, url string) error {
+	feedsMutex.Lock()
+	defer feedsMutex.Unlock()
+
+	feed, err, exists := db.Feeds.Get(url)
+	if err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(feed.Subscriptions, sameSubscription(server, channel))
+	if !exists || i == -1 {
+		return errors.New("not subscribed")
+	}
+
+	feed.Subscriptions = slices.Delete(feed.Subscriptions, i, i+1)
+	if len(feed.Subscriptions) == 0 {
+		return db.Feeds.Delete(url)
+	}
+
+	return db.Feeds.Put(url, feed)
+}
+
+// urls and feeds a channel is subscribed to
+func Subscriptions(server string, channel string) (map[string]db.Feed, error) {
+	feeds, err := db.Feeds.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[string]db.Feed{}
+	for url, feed := range feeds.AllFromFront() {
+		if slices.ContainsFunc(
+			feed.Subscriptions, sameSubscription(server, channel),
+		) {
+			out[url] = feed
+		}
+	}
+
+	return out, nil
+}
+
+func formatEntry(feedTitle string, entry Entry) string {
+	out := ""
+	if feedTitle != "" {
+		out += ircf.Color(98).Format("["+feedTitle+"]") + " "
+	}
+	title := entry.Title
+	if title == "" {
+		title = "untitled"
+	}
+	out += ircf.Bold().Format(title)
+	if entry.Link != "" {
+		out += " " + entry.Link
+	}
+	return out
+}
+
+func poll(ctx context.Context, url string) {
+	parsed, fetchErr := Fetch(ctx, url)
+
+	feedsMutex.Lock()
+	defer feedsMutex.Unlock()
+
+	// might have been removed whilst fetching
+	feed, err, exists := db.Feeds.Get(url)
+	if err != nil || !exists {
+		return
+	}
+
+	feed.LastPolled = time.Now()
+
+	if fetchErr != nil {
+		slog.Warn("failed to poll feed", "url", url, "err", fetchErr)
+		feed.LastError = fetchErr.Error()
+		err = db.Feeds.Put(url, feed)
+		if err != nil {
+			slog.Error("failed to put feed", "url", url, "err", err)
+		}
+		return
+	}
+
+	feed.LastError = ""
+	if parsed.Title != "" {
+		feed.Title = parsed.Title
+	}
+
+	var fresh []Entry
+	for _, entry := range parsed.Entries {
+		if entry.ID != "" && !slices.Contains(feed.Seen, entry.ID) {
+			fresh = append(fresh, entry)
+		}
+	}
+
+	// oldest first
+	slices.Reverse(fresh)
+
+	if len(fresh) > 0 {
+		lines := []string{}
+		for i, entry := range fresh {
+			if i == MAX_ANNOUNCE {
+				lines = append(lines, ircf.Color(98).Format(
+					fmt.Sprintf("and %d more", len(fresh)-MAX_ANNOUNCE),
+				))
+				break
+			}
+			lines = append(lines, formatEntry(feed.Title, entry))
+		}
+
+		for _, sub := range feed.Subscriptions {
+			send(sub.Server, sub.Channel, strings.Join(lines, "\n"))
+		}
+	}
+
+	for _, entry := range fresh {
+		feed.Seen = append(feed.Seen, entry.ID)
+	}
+	limit := max(MAX_SEEN, len(parsed.Entries)*2)
+	if len(feed.Seen) > limit {
+		feed.Seen = feed.Seen[len(feed.Seen)-limit:]
+	}
+
+	err = db.Feeds.Put(url, feed)
+	if err != nil {
+		slog.Error("failed to put feed", "url", url, "err", err)
+	}
+}
+
+func PollAll(ctx context.Context) {
+	feeds, err := db.Feeds.GetAll()
+	if err != nil {
+		slog.Error("failed to get feeds", "err", err)
+		return
+	}
+
+	for url := range feeds.Keys() {
+		poll(ctx, url)
+	}
+}
+
+func Start() {
+	go func() {
+		slog.Info("started feed loop", "interval", env.FEED_INTERVAL)
+		for {
+			time.Sleep(env.FEED_INTERVAL)
+			PollAll(context.Background())
+		}
+	}()
+}
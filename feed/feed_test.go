@@ -0,0 +1,232 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/env"
+	"github.com/makinori/mikogo/ircf"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "mikogo-feed")
+	if err != nil {
+		panic(err)
+	}
+
+	env.DB_PATH = filepath.Join(dir, "data.db")
+
+	err = db.Init()
+	if err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+
+	db.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+const testRSS = `<?xml version="1.0"?>
+<rss version="2.0">
+<channel>
+	<title>test blog</title>
+	%s
+</channel>
+</rss>`
+
+func rssItem(id string, title string) string {
+	return "<item><guid>" + id + "</guid><title>" + title +
+		"</title><link>https://example.com/" + id + "</link></item>"
+}
+
+func TestParseAtom(t *testing.T) {
+	parsed, err := Parse([]byte(`<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>atom blog</title>
+	<entry>
+		<id>urn:1</id>
+		<title>hello</title>
+		<link rel="alternate" href="https://example.com/1"/>
+	</entry>
+</feed>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Title != "atom blog" || !slices.Equal(parsed.Entries, []Entry{
+		{ID: "urn:1", Title: "hello", Link: "https://example.com/1"},
+	}) {
+		t.Fatalf("unexpected feed: %+v", parsed)
+	}
+
+	_, err = Parse([]byte(`<html></html>`))
+	if err != ErrNotFeed {
+		t.Fatalf("expected not a feed, got %v", err)
+	}
+}
+
+func TestParseSanitizes(t *testing.T) {
+	parsed, err := Parse([]byte(fmt.Sprintf(testRSS, rssItem(
+		"1", "line one&#13;\nPRIVMSG #other :injected \t title",
+	)+rssItem("2", strings.Repeat("a", MAX_TITLE_LENGTH*2)))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	title := parsed.Entries[0].Title
+	if title != "line one PRIVMSG #other :injected title" {
+		t.Fatalf("title wasn't sanitized: %q", title)
+	}
+
+	long := []rune(parsed.Entries[1].Title)
+	if len(long) != MAX_TITLE_LENGTH || long[len(long)-1] != '…' {
+		t.Fatalf("title wasn't capped: %d runes", len(long))
+	}
+}
+
+func TestParseLatin1(t *testing.T) {
+	parsed, err := Parse([]byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>" +
+		"<rss version=\"2.0\"><channel><title>caf\xe9</title>" +
+		rssItem("1", "na\xefve") + "</channel></rss>",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Title != "café" || parsed.Entries[0].Title != "naïve" {
+		t.Fatalf("unexpected feed: %+v", parsed)
+	}
+}
+
+type sent struct {
+	server  string
+	channel string
+	msg     string
+}
+
+func TestPoll(t *testing.T) {
+	var (
+		items   = []string{rssItem("1", "first")}
+		mutex   sync.Mutex
+		results []sent
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			w.Write([]byte(strings.Replace(
+				testRSS, "%s", strings.Join(items, "\n"), 1,
+			)))
+		},
+	))
+	defer server.Close()
+
+	previous := send
+	send = func(server string, channel string, msg string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		results = append(results, sent{server, channel, ircf.Strip(msg)})
+	}
+	defer func() { send = previous }()
+
+	ctx := context.Background()
+
+	title, err := Subscribe(ctx, "home", "#news", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != "test blog" {
+		t.Fatalf("unexpected title: %s", title)
+	}
+
+	_, err = Subscribe(ctx, "home", "#NEWS", server.URL)
+	if err == nil {
+		t.Fatal("subscribed twice")
+	}
+
+	// existing entries arent announced
+	PollAll(ctx)
+	if len(results) != 0 {
+		t.Fatalf("announced old entries: %+v", results)
+	}
+
+	mutex.Lock()
+	items = []string{
+		rssItem("3", "third"), rssItem("2", "second"), rssItem("1", "first"),
+	}
+	mutex.Unlock()
+
+	PollAll(ctx)
+	expected := []sent{{
+		"home", "#news",
+		"[test blog] second https://example.com/2\n" +
+			"[test blog] third https://example.com/3",
+	}}
+	if !slices.Equal(results, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, results)
+	}
+
+	// only once
+	PollAll(ctx)
+	if len(results) != 1 {
+		t.Fatalf("announced twice: %+v", results)
+	}
+
+	err = Unsubscribe("home", "#news", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, exists := db.Feeds.Get(server.URL)
+	if exists {
+		t.Fatal("feed kept without subscribers")
+	}
+}
+
+func TestSubscribeFetchesUnlocked(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.Write([]byte(fmt.Sprintf(testRSS, rssItem("1", "first"))))
+		},
+	))
+	defer slow.Close()
+
+	subscribed := make(chan error, 1)
+	go func() {
+		_, err := Subscribe(context.Background(), "home", "#slow", slow.URL)
+		subscribed <- err
+	}()
+
+	<-started
+	if !feedsMutex.TryLock() {
+		close(release)
+		t.Fatal("feeds locked whilst fetching")
+	}
+	feedsMutex.Unlock()
+	close(release)
+
+	err := <-subscribed
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Unsubscribe("home", "#slow", slow.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
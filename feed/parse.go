@@ -0,0 +1,155 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+
+	"github.com/makinori/mikogo/ircf"
+	"golang.org/x/net/html/charset"
+)
+
+type Entry struct {
+	ID    string
+	Title string
+	Link  string
+}
+
+type Parsed struct {
+	Title string
+	// in the order the feed lists them, usually newest first
+	Entries []Entry
+}
+
+// https://www.rssboard.org/rss-specification
+type rss struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			GUID  string `xml:"guid"`
+			Title string `xml:"title"`
+			Link  string `xml:"link"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// https://www.rfc-editor.org/rfc/rfc4287
+type atom struct {
+	Title   string `xml:"title"`
+	Entries []struct {
+		ID    string `xml:"id"`
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+var ErrNotFeed = errors.New("not an rss or atom feed")
+
+// plenty of feeds still declare latin-1 and friends
+func newDecoder(data []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder
+}
+
+// prefers guid or id, but not every feed has them
+func entryID(id string, link string, title string) string {
+	for _, value := range []string{id, link, title} {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func parseRSS(data []byte) (*Parsed, error) {
+	var feed rss
+	err := newDecoder(data).Decode(&feed)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &Parsed{Title: strings.TrimSpace(feed.Channel.Title)}
+	for _, item := range feed.Channel.Items {
+		parsed.Entries = append(parsed.Entries, Entry{
+			ID:    entryID(item.GUID, item.Link, item.Title),
+			Title: strings.TrimSpace(item.Title),
+			Link:  strings.TrimSpace(item.Link),
+		})
+	}
+
+	return parsed, nil
+}
+
+func parseAtom(data []byte) (*Parsed, error) {
+	var feed atom
+	err := newDecoder(data).Decode(&feed)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &Parsed{Title: strings.TrimSpace(feed.Title)}
+	for _, entry := range feed.Entries {
+		link := ""
+		for _, l := range entry.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = l.Href
+				break
+			}
+		}
+		parsed.Entries = append(parsed.Entries, Entry{
+			ID:    entryID(entry.ID, link, entry.Title),
+			Title: strings.TrimSpace(entry.Title),
+			Link:  strings.TrimSpace(link),
+		})
+	}
+
+	return parsed, nil
+}
+
+const (
+	MAX_TITLE_LENGTH = 200
+	MAX_LINK_LENGTH  = 400
+)
+
+// feeds are remote, so nothing in them should be able to add lines
+// or formatting to what we announce
+func sanitize(parsed *Parsed, err error) (*Parsed, error) {
+	if err != nil {
+		return nil, err
+	}
+	parsed.Title = ircf.Sanitize(parsed.Title, MAX_TITLE_LENGTH)
+	for i := range parsed.Entries {
+		entry := &parsed.Entries[i]
+		entry.Title = ircf.Sanitize(entry.Title, MAX_TITLE_LENGTH)
+		entry.Link = ircf.Sanitize(entry.Link, MAX_LINK_LENGTH)
+	}
+	return parsed, nil
+}
+
+// looks at the root element to tell rss and atom apart
+func Parse(data []byte) (*Parsed, error) {
+	decoder := newDecoder(data)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, ErrNotFeed
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "rss":
+			return sanitize(parseRSS(data))
+		case "feed":
+			return sanitize(parseAtom(data))
+		}
+		return nil, ErrNotFeed
+	}
+}
@@ -9,11 +9,13 @@ require (
 	github.com/go-gl/mathgl v1.2.0
 	github.com/joho/godotenv v1.5.1
 	go.etcd.io/bbolt v1.4.3
-	golang.org/x/sync v0.10.0
+	golang.org/x/net v0.47.0
+	golang.org/x/sync v0.18.0
 )
 
 require (
 	github.com/x448/float16 v0.8.4 // indirect
 	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8 // indirect
-	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/sys v0.38.0 // indirect
+	golang.org/x/text v0.31.0 // indirect
 )
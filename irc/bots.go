@@ -0,0 +1,55 @@
+package irc
+
+import (
+	"strings"
+)
+
+// https://ircv3.net/specs/extensions/bot-mode
+
+// from who replies
+func (c *Client) IsBot(nick string) bool {
+	c.botsMutex.RLock()
+	defer c.botsMutex.RUnlock()
+	return c.bots[strings.ToLower(nick)]
+}
+
+// returns true if handled
+func (c *Client) handleBotLines(line *Line) bool {
+	switch line.Command {
+	case "005": // isupport
+		for _, param := range line.Params {
+			mode, ok := strings.CutPrefix(param, "BOT=")
+			if ok && mode != "" {
+				c.botsMutex.Lock()
+				c.botMode = mode
+				c.botsMutex.Unlock()
+			}
+		}
+		return true
+
+	case "352": // who reply
+		// me channel user host server nick flags :hops realname
+		if len(line.Params) < 7 {
+			return true
+		}
+
+		c.botsMutex.Lock()
+		defer c.botsMutex.Unlock()
+
+		nick := strings.ToLower(line.Params[5])
+		if strings.Contains(line.Params[6], c.botMode) {
+			c.bots[nick] = true
+		} else {
+			delete(c.bots, nick)
+		}
+		return true
+	}
+
+	return false
+}
+
+func (m *Message) IsBot() bool {
+	_, tagged := m.Tags["bot"]
+	_, draftTagged := m.Tags["draft/bot"]
+	return tagged || draftTagged || m.Client.IsBot(m.Sender)
+}
@@ -0,0 +1,56 @@
+package irc
+
+import (
+	"strings"
+)
+
+// https://ircv3.net/specs/extensions/capability-negotiation
+
+// requested one per line so a nak doesnt lose the others
+var requestedCaps = []string{
+	// for msgid, replies, reactions and typing
+	"message-tags",
+	"account-tag",
+}
+
+func (c *Client) requestCaps() {
+	c.capsMutex.Lock()
+	c.caps = map[string]bool{}
+	c.capsPending = len(requestedCaps)
+	c.capsMutex.Unlock()
+
+	for _, name := range requestedCaps {
+		c.writef("CAP REQ :%s\r\n", name)
+	}
+}
+
+// registration waits until CAP END, which we send once every request
+// has been acked or naked
+func (c *Client) handleCap(line *Line) {
+	sub := strings.ToUpper(line.Param(1))
+	if sub != "ACK" && sub != "NAK" {
+		return
+	}
+
+	c.capsMutex.Lock()
+	for name := range strings.FieldsSeq(line.Param(2)) {
+		c.caps[name] = sub == "ACK"
+	}
+	c.capsPending--
+	done := c.capsPending == 0
+	c.capsMutex.Unlock()
+
+	if sub == "NAK" {
+		c.slog().Warn("cap not supported", "caps", line.Param(2))
+	}
+
+	if done {
+		c.writef("CAP END\r\n")
+	}
+}
+
+func (c *Client) hasCap(name string) bool {
+	c.capsMutex.RLock()
+	defer c.capsMutex.RUnlock()
+	return c.caps[name]
+}
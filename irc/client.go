@@ -13,13 +13,14 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/makinori/mikogo/env"
+	"github.com/makinori/mikogo/db"
 	"github.com/makinori/mikogo/ircf"
 )
 
-// TODO: better logging system so we dont keep writing "server", c.Address
+// TODO: better logging system so we dont keep writing "server", c.Name
 
 type ConnState = uint8
 
@@ -28,57 +29,226 @@ const (
 	ConnStateConnected
 	ConnStateDisconnected
 
-	RECONNECT_DURATION = time.Second * 10
+	// how long messages wait in the outbox whilst disconnected
+	OUTBOX_EXPIRY = time.Hour
 )
 
 var (
-	RE_PRIVMSG     = regexp.MustCompile(`^:(.+?)!.+? PRIVMSG (.+?) :(.+?)\r\n$`)
-	RE_KICK        = regexp.MustCompile(`^:(.+?)!.+? KICK (#.+?) .+? :?(.+?)\r\n$`)
+	// var so tests can shorten it
+	RECONNECT_DURATION = time.Second * 10
+
+	RE_PRIVMSG     = regexp.MustCompile(`^:(.+?)!(.+?) PRIVMSG (.+?) :(.+?)\r\n$`)
+	RE_KICK        = regexp.MustCompile(`^:(.+?)!.+? KICK (#.+?) (.+?) :?(.+?)\r\n$`)
+	RE_INVITE      = regexp.MustCompile(`^:(.+?)!.+? INVITE (.+?) :?(#.+?)\r\n$`)
 	RE_WHOIS_REPLY = regexp.MustCompile(`^:.+? 311 .+ (.+?) (.+?) (.+?) \* (.+?)\r\n$`)
 )
 
-type Message struct {
-	Client  *Client
-	Sender  string
-	Where   string
-	Message string
-}
-
-var GlobalHandleMessage func(msg *Message)
-
 type Client struct {
-	Address string
-	active  bool // for starting/stopping the client
+	Name string
+
+	// sync changes these whilst the connect loop reads them
+	addresses []string
+	// index of current or last good address
+	address     int
+	identity    Identity
+	configMutex *sync.RWMutex
 
-	Conn  *tls.Conn
-	state ConnState
+	// other goroutines read these whilst the connect loop changes them
+	active bool // for starting/stopping the client
+	state  ConnState
+	// can differ from identity if taken
+	nick       string
+	user       string
+	host       string
+	stateMutex *sync.RWMutex
 
-	user string
-	host string
+	// under writeMutex
+	conn *tls.Conn
 
-	PanicOnNextPing bool
+	PanicOnNextPing atomic.Bool
 
 	channelsCurrent []string
 	channelsTarget  []string
 	channelsMutex   *sync.RWMutex
+
+	writeMutex  *sync.Mutex
+	outboxMutex *sync.RWMutex
+
+	// nicks with bot mode from who replies
+	bots      map[string]bool
+	botMode   string
+	botsMutex *sync.RWMutex
+
+	stats      Stats
+	statsMutex *sync.Mutex
+
+	// acked caps for this connection
+	caps        map[string]bool
+	capsPending int
+	capsMutex   *sync.RWMutex
+
+	middlewares *middlewares
+	trace       *tracer
+	traceMutex  *sync.Mutex
 }
 
 func (c *Client) slog() *slog.Logger {
-	return slog.Default().With("server", c.Address)
+	return slog.Default().With("server", c.Name)
 }
 
-func (c *Client) FormattedState() string {
-	switch c.state {
+func (c *Client) currentAddress() string {
+	if len(c.addresses) == 0 {
+		return ""
+	}
+	return c.addresses[c.address%len(c.addresses)]
+}
+
+func (c *Client) Address() string {
+	c.configMutex.RLock()
+	defer c.configMutex.RUnlock()
+	return c.currentAddress()
+}
+
+// keeps the current address if its still in the list
+func (c *Client) setAddresses(addresses []string) (changed bool) {
+	c.configMutex.Lock()
+	defer c.configMutex.Unlock()
+
+	current := c.currentAddress()
+	c.addresses = slices.Concat(addresses) // make copy
+
+	i := slices.Index(c.addresses, current)
+	if i == -1 {
+		c.address = 0
+		return true
+	}
+
+	c.address = i
+	return false
+}
+
+func (c *Client) nextAddress() {
+	c.configMutex.Lock()
+	if len(c.addresses) < 2 {
+		c.configMutex.Unlock()
+		return
+	}
+	c.address = (c.address + 1) % len(c.addresses)
+	address := c.currentAddress()
+	c.configMutex.Unlock()
+
+	c.slog().Info("trying next address", "addr", address)
+}
+
+// so the next start tries it first
+func (c *Client) saveLastAddress() {
+	address := c.Address()
+	err := db.Servers.Update(c.Name, func(server *db.Server) error {
+		server.LastAddress = address
+		return nil
+	})
+	if err != nil {
+		c.slog().Warn("failed to save last address", "err", err)
+	}
+}
+
+func (c *Client) getIdentity() Identity {
+	c.configMutex.RLock()
+	defer c.configMutex.RUnlock()
+	return c.identity
+}
+
+func (c *Client) setIdentity(identity Identity) (changed bool) {
+	c.configMutex.Lock()
+	defer c.configMutex.Unlock()
+	changed = c.identity != identity
+	c.identity = identity
+	return changed
+}
+
+func (c *Client) isActive() bool {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+	return c.active
+}
+
+func (c *Client) setActive(active bool) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	c.active = active
+}
+
+func (c *Client) getState() ConnState {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+	return c.state
+}
+
+func (c *Client) setState(state ConnState) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	c.state = state
+}
+
+func (c *Client) setNick(nick string) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	c.nick = nick
+}
+
+// user and host from our own whois
+func (c *Client) mask() (user string, host string) {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+	return c.user, c.host
+}
+
+func (c *Client) setMask(user string, host string) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	c.user = user
+	c.host = host
+}
+
+func (c *Client) StateName() string {
+	state := c.getState()
+	switch state {
 	case ConnStateConnecting:
-		return ircf.Bold().Color(98, 41).Format("connecting")
+		return "connecting"
 	case ConnStateConnected:
-		return ircf.Bold().Color(98, 43).Format("connected")
+		return "connected"
 	case ConnStateDisconnected:
-		return ircf.Bold().Color(98, 40).Format("disconnected")
+		return "disconnected"
 	}
-	return ircf.Bold().Color(98, 40).Format(
-		fmt.Sprintf("unknown: %v", c.state),
-	)
+	return fmt.Sprintf("unknown: %v", state)
+}
+
+// colored state name for irc
+func FormatState(state string) string {
+	switch state {
+	case "connecting":
+		return ircf.Bold().Color(98, 41).Format(state)
+	case "connected":
+		return ircf.Bold().Color(98, 43).Format(state)
+	}
+	return ircf.Bold().Color(98, 40).Format(state)
+}
+
+func (c *Client) FormattedState() string {
+	return FormatState(c.StateName())
+}
+
+// current nick, which might differ from the identity if it was taken
+func (c *Client) Nick() string {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+	return c.nick
+}
+
+func (c *Client) Connected() bool {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+	return c.active && c.state == ConnStateConnected
 }
 
 func (c *Client) CurrentChannels() []string {
@@ -94,7 +264,7 @@ func (c *Client) setTargetChannels(target []string) {
 }
 
 func (c *Client) SyncChannels() {
-	if !c.active || c.state != ConnStateConnected {
+	if !c.Connected() {
 		c.slog().Warn("can't sync channels if inactive or disconnected")
 		return
 	}
@@ -112,7 +282,9 @@ func (c *Client) SyncChannels() {
 			continue
 		}
 
-		fmt.Fprintf(c.Conn, "JOIN %s\r\n", target)
+		c.writef("JOIN %s\r\n", target)
+		// to find out who the bots are
+		c.writef("WHO %s\r\n", target)
 		// TODO: implementation doesnt handle JOIN fails
 		c.channelsCurrent = append(c.channelsCurrent, target)
 		c.slog().Info("channel joined", "name", target)
@@ -126,58 +298,132 @@ func (c *Client) SyncChannels() {
 			continue
 		}
 
-		fmt.Fprintf(c.Conn, "PART %s\r\n", current)
+		c.writef("PART %s\r\n", current)
 		c.slog().Info("channel left", "name", current)
 	}
 	c.channelsCurrent = c.channelsCurrent[:i]
 }
 
+// all writes go through here so lines from different goroutines dont interleave
+func (c *Client) write(lines ...string) bool {
+	return c.writeLines(lines...) == len(lines)
+}
+
+// returns how many lines went out or were dropped by middleware
+func (c *Client) writeLines(lines ...string) int {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if c.conn == nil {
+		c.slog().Warn("can't write whilst disconnected", "lines", len(lines))
+		return 0
+	}
+
+	for i, line := range lines {
+		line, ok := c.runOutbound(line)
+		if !ok {
+			continue
+		}
+		n, err := io.WriteString(c.conn, line)
+		c.updateStats(func(stats *Stats) {
+			stats.LinesOut++
+			stats.BytesOut += uint64(n)
+		})
+		if err != nil {
+			c.slog().Warn("failed to write", "err", err)
+			c.statsError("write: " + err.Error())
+			return i
+		}
+	}
+
+	return len(lines)
+}
+
+func (c *Client) writef(format string, a ...any) {
+	c.write(fmt.Sprintf(format, a...))
+}
+
+// line doesnt need to end with \r\n
+func (c *Client) WriteRaw(line string) {
+	c.write(strings.TrimRight(line, "\r\n") + "\r\n")
+}
+
 func (c *Client) MakePrivmsg(to string, msg string) string {
+	c.stateMutex.RLock()
 	out := fmt.Sprintf(":%s!%s@%s PRIVMSG %s :%s\r\n",
-		env.NICK, c.user, c.host, to, msg,
+		c.nick, c.user, c.host, to, msg,
 	)
+	c.stateMutex.RUnlock()
 	if len(out) > 512 {
 		c.slog().Warn("sent message too large", "bytes", len(out))
 	}
 	return out
 }
 
-func (c *Client) writeBatch(to string, lines []string) {
+func (c *Client) writeBatch(tags Tags, to string, lines []string) bool {
 	id := fmt.Sprintf("%03d", rand.Intn(1000))
-	fmt.Fprintf(c.Conn, "BATCH +%s draft/multiline %s\r\n", id, to)
+
+	out := make([]string, 0, len(lines)+2)
+	out = append(out, fmt.Sprintf("%sBATCH +%s draft/multiline %s\r\n",
+		tags.prefix(), id, to,
+	))
 	for i := range lines {
-		fmt.Fprintf(c.Conn, "@batch=%s %s", id, c.MakePrivmsg(to, lines[i]))
+		out = append(out, fmt.Sprintf(
+			"@batch=%s %s", id, c.MakePrivmsg(to, lines[i]),
+		))
 	}
-	fmt.Fprintf(c.Conn, "BATCH -%s\r\n", id)
+	out = append(out, fmt.Sprintf("BATCH -%s\r\n", id))
+
+	// once some of it is out, queueing it again would repeat those lines
+	return c.writeLines(out...) > 0
 }
 
-func (c *Client) Send(to, msg string) {
+// tags go on the batch if multiline
+func (c *Client) writePrivmsg(tags Tags, to, msg string) bool {
 	// TODO: handle messages over 512 bytes
 	// SplitStringBySpace function available in old branch
 
 	lines := strings.Split(msg, "\n")
 	if len(lines) == 1 {
-		fmt.Fprint(c.Conn, c.MakePrivmsg(to, msg))
-		return
+		return c.write(tags.prefix() + c.MakePrivmsg(to, msg))
 	}
 
-	c.writeBatch(to, lines)
+	return c.writeBatch(tags, to, lines)
 }
 
-func (c *Client) handleKick(sender string, where string, reason string) {
-	c.channelsMutex.Lock()
-	defer c.channelsMutex.Unlock()
+// will be queued in the outbox if disconnected
+func (c *Client) Send(to, msg string) {
+	c.SendTagged(nil, to, msg)
+}
+
+func (c *Client) SendTagged(tags Tags, to, msg string) {
+	c.send(tags, to, msg, time.Now().Add(OUTBOX_EXPIRY))
+}
+
+// for client-only tags like typing and reactions.
+// returns false if the server doesnt support them
+func (c *Client) SendTagmsg(tags Tags, to string) bool {
+	if !c.hasCap("message-tags") {
+		return false
+	}
+	c.writef("%sTAGMSG %s\r\n", tags.prefix(), to)
+	return true
+}
 
+func (c *Client) handleKick(sender string, where string, reason string) {
 	c.slog().Info("kicked", "sender", sender, "where", where, "reason", reason)
 
-	ReportIncident(fmt.Sprintf(
-		"kicked from %s by %s on %s for %s",
+	// before locking as reporting needs the clients mutex
+	ReportIncident(SeverityWarn, c.Name, fmt.Sprintf(
+		"kicked from %s by %s for %s",
 		ircf.BoldWhite.Format(where),
 		ircf.BoldWhite.Format(sender),
-		ircf.BoldWhite.Format(c.Address),
 		ircf.BoldWhite.Format(reason),
 	))
 
+	c.channelsMutex.Lock()
+	defer c.channelsMutex.Unlock()
+
 	i := slices.Index(c.channelsCurrent, where)
 	if i == -1 {
 		c.slog().Warn("was never in channel?", "where", where)
@@ -187,139 +433,232 @@ func (c *Client) handleKick(sender string, where string, reason string) {
 	c.channelsCurrent = slices.Delete(c.channelsCurrent, i, i+1)
 }
 
-func (c *Client) handleMessage(msg string) {
-	// debugMsg := msg
-	// debugMsg = strings.ReplaceAll(debugMsg, "\r", "\\r")
-	// debugMsg = strings.ReplaceAll(debugMsg, "\n", "\\n")
-	// fmt.Println(debugMsg)
+// only rejoins channels we want to be in
+func (c *Client) handleInvite(sender string, where string) {
+	c.channelsMutex.RLock()
+	wanted := slices.Contains(c.channelsTarget, where)
+	c.channelsMutex.RUnlock()
+
+	c.slog().Info("invited", "sender", sender, "where", where, "wanted", wanted)
+
+	if wanted {
+		c.SyncChannels()
+	}
+}
+
+func (c *Client) handleMessage(line *Line) {
+	// without tags so the regexes below dont have to care about them
+	tags := line.Tags
+	msg := line.format(false) + "\r\n"
 
 	// handle privmsg first cause we dont want anyone to attack the below
 	matches := RE_PRIVMSG.FindStringSubmatch(msg)
 	if len(matches) > 0 {
 		sender := matches[1]
-		where := matches[2]
+		where := matches[3]
 		if !strings.HasPrefix(where, "#") {
 			// if direct message, "where" ends up being our nick
 			where = sender
 		}
-		go GlobalHandleMessage(&Message{
+		dispatch(&Message{
 			Client:  c,
+			ID:      tags["msgid"],
+			Tags:    tags,
 			Sender:  sender,
+			Mask:    sender + "!" + matches[2],
+			Account: tags["account"],
 			Where:   where,
-			Message: matches[3],
+			Message: matches[4],
 		})
 		return
 	}
 
-	if c.state == ConnStateConnecting &&
-		strings.Contains(msg, " 001 "+env.NICK+" ") {
-		c.slog().Info("connected!")
-		c.state = ConnStateConnected
+	if line.Command == "PONG" {
+		c.handlePong()
+		return
+	}
+
+	if line.Command == "CAP" {
+		c.handleCap(line)
+		return
+	}
+
+	nick := c.Nick()
+
+	if c.getState() == ConnStateConnecting &&
+		strings.Contains(msg, " 001 "+nick+" ") {
+		c.slog().Info("connected!", "addr", c.Address(), "nick", nick)
+
+		// held until flushed so a send once connected cant go out
+		// ahead of older queued messages
+		c.outboxMutex.Lock()
+		defer c.outboxMutex.Unlock()
+
+		c.setState(ConnStateConnected)
+		c.updateStats(func(stats *Stats) {
+			if stats.connectedBefore {
+				stats.Reconnects++
+			}
+			stats.connectedBefore = true
+			stats.Connects++
+			stats.ConnectedAt = time.Now()
+		})
+		c.saveLastAddress()
+		c.welcome()
 		c.SyncChannels()
+		c.flushOutbox()
+		return
+	}
+
+	// nick in use during registration
+	if c.getState() == ConnStateConnecting &&
+		strings.Contains(msg, " 433 * "+nick+" ") {
+		c.slog().Warn("nick in use. trying another", "nick", nick)
+		c.setNick(nick + "_")
+		c.writef("NICK %s\r\n", nick+"_")
+		return
+	}
+
+	if c.handleBotLines(line) {
 		return
 	}
 
 	matches = RE_KICK.FindStringSubmatch(msg)
 	if len(matches) > 0 {
-		c.handleKick(matches[1], matches[2], matches[3])
+		if matches[3] == nick {
+			c.handleKick(matches[1], matches[2], matches[4])
+		}
+		return
+	}
+
+	matches = RE_INVITE.FindStringSubmatch(msg)
+	if len(matches) > 0 {
+		if matches[2] == nick {
+			c.handleInvite(matches[1], matches[3])
+		}
 		return
 	}
 
 	// response to self whois
 	// TODO: what if server changes our mask?
 
-	if c.user == "" && c.host == "" && strings.Contains(
-		msg, " 311 "+env.NICK+" "+env.NICK,
+	if user, host := c.mask(); user == "" && host == "" && strings.Contains(
+		msg, " 311 "+nick+" "+nick,
 	) {
 		matches := RE_WHOIS_REPLY.FindStringSubmatch(msg)
 		if len(matches) == 0 {
 			return
 		}
 
-		if matches[1] != env.NICK {
+		if matches[1] != nick {
 			return
 		}
 
-		c.user = matches[2]
-		c.host = matches[3]
-		c.slog().Info("got mask", "mask", c.user+"@"+c.host)
+		c.setMask(matches[2], matches[3])
+		c.slog().Info("got mask", "mask", matches[2]+"@"+matches[3])
 
 		return
 	}
 }
 
-func (c *Client) connect() {
-	if c.Conn != nil {
-		c.Conn.Close()
-		c.Conn = nil
+// closes the connection, which makes the connect loop return
+func (c *Client) closeConn() {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
 	}
+}
+
+func (c *Client) connect() {
+	c.closeConn()
+
+	c.setState(ConnStateConnecting)
 
-	c.state = ConnStateConnecting
+	// mask might be different this time
+	c.setMask("", "")
 
-	var err error
-	c.Conn, err = tls.Dial("tcp", c.Address, &tls.Config{
+	// and we're not in any channels yet
+	c.channelsMutex.Lock()
+	c.channelsCurrent = nil
+	c.channelsMutex.Unlock()
+
+	conn, err := tls.Dial("tcp", c.Address(), &tls.Config{
 		InsecureSkipVerify: true,
 	})
 	if err != nil {
-		c.slog().Warn("failed to connect. retrying...", "err", err)
+		c.slog().Warn(
+			"failed to connect. retrying...",
+			"addr", c.Address(), "err", err,
+		)
+		c.statsError("connect: " + err.Error())
+		c.nextAddress()
 		return
 	}
 
-	// TODO: what if nick not available
-
-	fmt.Fprintf(c.Conn, "NICK %s\r\n", env.NICK)
-	fmt.Fprintf(c.Conn, "USER %s 0 * :%s\r\n",
-		env.NICK, env.NICK,
-	)
-
-	// bot mode b or B
-	fmt.Fprintf(c.Conn, "MODE %s +b\r\n", env.NICK)
-	fmt.Fprintf(c.Conn, "MODE %s +B\r\n", env.NICK)
+	c.writeMutex.Lock()
+	c.conn = conn
+	c.writeMutex.Unlock()
 
-	// self whois for privmsg prefix
-	fmt.Fprintf(c.Conn, "WHOIS %s\r\n", env.NICK)
+	c.register()
 
-	reader := bufio.NewReader(c.Conn)
+	reader := bufio.NewReader(conn)
 	for {
 		msg, err := reader.ReadString('\n')
-		if err == io.EOF || errors.Is(err, net.ErrClosed) {
-			c.state = ConnStateDisconnected
-			c.Conn = nil
-			if c.active {
+		if err != nil {
+			active := c.isActive()
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				c.slog().Error("failed to read message", "err", err)
+				c.statsError("read: " + err.Error())
+			} else if active {
+				c.statsError("disconnected")
+			}
+			c.updateStats(func(stats *Stats) {
+				stats.ConnectedAt = time.Time{}
+			})
+			// address is only good if we got through registration
+			registered := c.getState() == ConnStateConnected
+			c.setState(ConnStateDisconnected)
+			c.closeConn()
+			if active {
 				c.slog().Warn("disconnected. retrying...")
+				if !registered {
+					c.nextAddress()
+				}
 			} else {
 				c.slog().Info("disconnected by request")
 			}
 			break
-		} else if err != nil {
-			c.slog().Error("failed to read message", "err", err)
-			// can just move on i suppose?
+		}
+		c.updateStats(func(stats *Stats) {
+			stats.LinesIn++
+			stats.BytesIn += uint64(len(msg))
+		})
+
+		line := ParseLine(msg)
+		if !c.runInbound(line) {
 			continue
 		}
-		c.handleMessage(msg)
+		c.handleMessage(line)
 	}
 }
 
 // will set active to false
 func (c *Client) delete() {
-	c.active = false
-	if c.Conn != nil {
-		c.Conn.Close()
-		c.Conn = nil
-	}
+	c.setActive(false)
+	c.saveStats()
+	c.closeConn()
 }
 
 func (c *Client) reconnect() {
-	if !c.active {
+	if !c.isActive() {
 		c.init()
 		return
 	}
 
 	// will cause connection loop to reconnect
-	if c.Conn != nil {
-		c.Conn.Close()
-		c.Conn = nil
-	}
+	c.closeConn()
 }
 
 func (c *Client) recoverAndRestart() {
@@ -328,18 +667,18 @@ func (c *Client) recoverAndRestart() {
 		return
 	}
 	c.slog().Error("client panic", "err", r)
-	c.PanicOnNextPing = false
+	c.PanicOnNextPing.Store(false)
 	c.reconnect()
 }
 
 func (c *Client) loop() {
 	defer c.recoverAndRestart()
 	for {
-		if !c.active {
+		if !c.isActive() {
 			return
 		}
 		c.connect() // will return if client disconnects
-		if !c.active {
+		if !c.isActive() {
 			return
 		}
 		time.Sleep(RECONNECT_DURATION)
@@ -347,25 +686,30 @@ func (c *Client) loop() {
 }
 
 func (c *Client) ping() {
-	if !c.active || c.state != ConnStateConnected {
+	if !c.Connected() {
 		return
 	}
 
 	defer c.recoverAndRestart()
-	if c.PanicOnNextPing {
+	if c.PanicOnNextPing.Load() {
 		panic("test panic")
 	}
 
-	fmt.Fprintf(c.Conn, "PING hi\r\n")
+	c.updateStats(func(stats *Stats) {
+		stats.pingSent = time.Now()
+	})
+	c.writef("PING hi\r\n")
 }
 
 func (c *Client) init() bool {
+	c.stateMutex.Lock()
 	if c.active {
+		c.stateMutex.Unlock()
 		c.slog().Warn("can't init client that's already active")
 		return false
 	}
-
 	c.active = true
+	c.stateMutex.Unlock()
 
 	c.slog().Info("connecting...")
 
@@ -374,9 +718,26 @@ func (c *Client) init() bool {
 	return true
 }
 
-func newClient(address string) *Client {
-	return &Client{
-		Address:       address,
+func newClient(name string, server db.Server) *Client {
+	client := &Client{
+		stats:         loadStats(name),
+		statsMutex:    &sync.Mutex{},
+		caps:          map[string]bool{},
+		capsMutex:     &sync.RWMutex{},
+		Name:          name,
+		addresses:     slices.Concat(server.Addresses), // make copy
+		identity:      identityFromServer(server),
+		configMutex:   &sync.RWMutex{},
+		stateMutex:    &sync.RWMutex{},
 		channelsMutex: &sync.RWMutex{},
+		writeMutex:    &sync.Mutex{},
+		outboxMutex:   &sync.RWMutex{},
+		bots:          map[string]bool{},
+		botMode:       "B",
+		botsMutex:     &sync.RWMutex{},
+		middlewares:   &middlewares{},
+		traceMutex:    &sync.Mutex{},
 	}
+	client.address = max(0, slices.Index(client.addresses, server.LastAddress))
+	return client
 }
@@ -0,0 +1,502 @@
+package irc
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/env"
+	"github.com/makinori/mikogo/irc/irctest"
+)
+
+var (
+	testHome     *irctest.Home
+	testMessages = make(chan *Message, 64)
+)
+
+func TestMain(m *testing.M) {
+	RECONNECT_DURATION = time.Millisecond * 100
+
+	var err error
+	testHome, err = irctest.StartHome("mikogo-irc", Sync)
+	if err != nil {
+		panic(err)
+	}
+
+	GlobalHandleMessage = func(msg *Message) {
+		testMessages <- msg
+	}
+
+	code := m.Run()
+
+	testHome.Close()
+	os.Exit(code)
+}
+
+func waitFor(t *testing.T, what string, fn func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(irctest.TIMEOUT)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func addTestServer(
+	t *testing.T, server *irctest.Server, name string, channels ...string,
+) *Client {
+	t.Helper()
+
+	err := db.Servers.Put(name, db.Server{
+		Addresses: []string{server.Addr},
+		Channels:  channels,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Servers.Delete(name)
+		Sync()
+	})
+
+	client := GetClient(name)
+	if client == nil {
+		t.Fatal("client not created")
+	}
+	return client
+}
+
+func TestConnect(t *testing.T) {
+	server := irctest.NewTestServer(t)
+	client := addTestServer(t, server, "connect", "#test")
+	conn := server.Accept(t)
+
+	conn.Expect(t, `^CAP REQ :message-tags$`)
+	conn.Expect(t, `^NICK mikogo$`)
+	conn.Expect(t, `^USER mikogo 0 \* :mikogo$`)
+	conn.Expect(t, `^CAP END$`)
+	conn.Expect(t, `^MODE mikogo \+b$`)
+	conn.Expect(t, `^WHOIS mikogo$`)
+	conn.Expect(t, `^JOIN #test$`)
+
+	waitFor(t, "connected", client.Connected)
+	waitFor(t, "mask", func() bool {
+		user, host := client.mask()
+		return user == "mikogo" && host == irctest.Host
+	})
+
+	if !slices.Equal(client.CurrentChannels(), []string{"#test"}) {
+		t.Fatalf("unexpected channels: %v", client.CurrentChannels())
+	}
+}
+
+func TestNickInUse(t *testing.T) {
+	server := irctest.NewTestServer(t)
+	server.SetTakenNicks("mikogo")
+
+	client := addTestServer(t, server, "nickinuse")
+	conn := server.Accept(t)
+
+	conn.Expect(t, `^NICK mikogo$`)
+	conn.Expect(t, `^NICK mikogo_$`)
+	conn.Expect(t, `^WHOIS mikogo_$`)
+
+	waitFor(t, "connected", client.Connected)
+	if client.Nick() != "mikogo_" {
+		t.Fatalf("expected nick mikogo_, got %s", client.Nick())
+	}
+}
+
+func TestChannelSync(t *testing.T) {
+	server := irctest.NewTestServer(t)
+	client := addTestServer(t, server, "channelsync", "#one")
+	conn := server.Accept(t)
+
+	conn.ExpectRegistered(t)
+	conn.Expect(t, `^JOIN #one$`)
+
+	err := db.Servers.Put("channelsync", db.Server{
+		Addresses: []string{server.Addr},
+		Channels:  []string{"#two"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Sync()
+
+	conn.Expect(t, `^JOIN #two$`)
+	conn.Expect(t, `^PART #one$`)
+
+	waitFor(t, "channels synced", func() bool {
+		return slices.Equal(client.CurrentChannels(), []string{"#two"})
+	})
+}
+
+func TestKickAndInvite(t *testing.T) {
+	home := testHome.Conn(t)
+
+	server := irctest.NewTestServer(t)
+	client := addTestServer(t, server, "kick", "#test")
+	conn := server.Accept(t)
+
+	conn.ExpectRegistered(t)
+	conn.Expect(t, `^JOIN #test$`)
+	waitFor(t, "connected", client.Connected)
+
+	// someone else getting kicked shouldnt matter.
+	// lines are handled in order, so the privmsg means the kick was seen
+	conn.Kick("op", "#test", "someone", "bye")
+	conn.Privmsg("", "alice", "#test", "after kick")
+	select {
+	case <-testMessages:
+	case <-time.After(irctest.TIMEOUT):
+		t.Fatal("timed out waiting for message")
+	}
+	if len(client.CurrentChannels()) != 1 {
+		t.Fatal("left channel when someone else was kicked")
+	}
+
+	conn.Kick("op", "#test", "mikogo", "go away")
+
+	waitFor(t, "channel removed", func() bool {
+		return len(client.CurrentChannels()) == 0
+	})
+
+	home.Expect(t, `PRIVMSG `+env.OWNER+` :.*kicked from .*#test.* by .*op.* for .*go away`)
+
+	conn.Invite("op", "mikogo", "#elsewhere")
+	conn.Invite("op", "mikogo", "#test")
+	conn.Expect(t, `^JOIN #test$`)
+
+	for _, line := range conn.Log() {
+		if line == "JOIN #elsewhere" {
+			t.Fatal("joined channel that wasn't wanted")
+		}
+	}
+}
+
+func TestIncidentDigest(t *testing.T) {
+	home := testHome.Conn(t)
+	waitFor(t, "home connected", GetClient("home").Connected)
+
+	window := INCIDENT_RATE_WINDOW
+	INCIDENT_RATE_WINDOW = time.Millisecond * 500
+	t.Cleanup(func() { INCIDENT_RATE_WINDOW = window })
+
+	incidentMutex.Lock()
+	incidentDeliveries = nil
+	incidentMutex.Unlock()
+
+	for i := range INCIDENT_MAX_PER_WINDOW + 2 {
+		ReportIncident(SeverityWarn, "digest", fmt.Sprintf("digest test %d", i))
+	}
+
+	for i := range INCIDENT_MAX_PER_WINDOW {
+		home.Expect(t, fmt.Sprintf(`:.*incident.* digest test %d$`, i))
+	}
+	home.Expect(t, `:.*incident.* 2 more whilst rate limited: #\d+ #\d+$`)
+}
+
+func TestReconnect(t *testing.T) {
+	server := irctest.NewTestServer(t)
+	client := addTestServer(t, server, "reconnect", "#test")
+
+	conn := server.Accept(t)
+	conn.ExpectRegistered(t)
+	conn.Expect(t, `^JOIN #test$`)
+	waitFor(t, "connected", client.Connected)
+
+	conn.Close()
+
+	conn = server.Accept(t)
+	conn.ExpectRegistered(t)
+	conn.Expect(t, `^JOIN #test$`)
+	waitFor(t, "reconnect counted", func() bool {
+		return client.Stats().Connects == 2
+	})
+
+	stats := client.Stats()
+	if stats.Reconnects != 1 {
+		t.Fatalf("unexpected connects: %+v", stats)
+	}
+	if stats.LinesIn == 0 || stats.LinesOut == 0 || stats.LastError == "" {
+		t.Fatalf("stats weren't counted: %+v", stats)
+	}
+}
+
+func TestPassWithSpaces(t *testing.T) {
+	server := irctest.NewTestServer(t)
+
+	err := db.Servers.Put("pass", db.Server{
+		Addresses: []string{server.Addr},
+		Pass:      ":hunter 2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Servers.Delete("pass")
+		Sync()
+	})
+	Sync()
+
+	conn := server.Accept(t)
+	conn.Expect(t, `^PASS ::hunter 2$`)
+	conn.ExpectRegistered(t)
+}
+
+func TestLastAddress(t *testing.T) {
+	server := irctest.NewTestServer(t)
+
+	// nothing listens on port 1
+	addresses := []string{"127.0.0.1:1", server.Addr}
+	err := db.Servers.Put("lastaddress", db.Server{Addresses: addresses})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Servers.Delete("lastaddress")
+		Sync()
+	})
+
+	conn := server.Accept(t)
+	conn.ExpectRegistered(t)
+
+	var saved db.Server
+	waitFor(t, "last address saved", func() bool {
+		saved, _, _ = db.Servers.Get("lastaddress")
+		return saved.LastAddress == server.Addr
+	})
+
+	// as if restarted
+	if address := newClient("lastaddress", saved).Address(); address != server.Addr {
+		t.Fatalf("expected to start on %s, got %s", server.Addr, address)
+	}
+}
+
+func TestPersistedConnectsArentReconnects(t *testing.T) {
+	env.PERSIST_STATS = true
+	t.Cleanup(func() {
+		env.PERSIST_STATS = false
+		db.Stats.Delete("persisted")
+	})
+
+	err := db.Stats.Put("persisted", db.ServerStats{Connects: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := irctest.NewTestServer(t)
+	client := addTestServer(t, server, "persisted")
+	server.Accept(t).ExpectRegistered(t)
+	waitFor(t, "connect counted", func() bool {
+		return client.Stats().Connects == 4
+	})
+
+	if stats := client.Stats(); stats.Reconnects != 0 {
+		t.Fatalf("unexpected connects: %+v", stats)
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	server := irctest.NewTestServer(t)
+	client := addTestServer(t, server, "outbox")
+
+	conn := server.Accept(t)
+	conn.ExpectRegistered(t)
+	waitFor(t, "connected", client.Connected)
+
+	conn.Close()
+	waitFor(t, "disconnected", func() bool {
+		return !client.Connected()
+	})
+
+	client.Send("someone", "sent whilst disconnected")
+
+	// sent as soon as it's connected, still after the queued one
+	sentAfter := make(chan struct{})
+	go func() {
+		defer close(sentAfter)
+		for !client.Connected() {
+			runtime.Gosched()
+		}
+		client.Send("someone", "sent once connected")
+	}()
+
+	conn = server.Accept(t)
+	conn.Expect(t, `PRIVMSG someone :sent whilst disconnected$`)
+	conn.Expect(t, `PRIVMSG someone :sent once connected$`)
+	<-sentAfter
+}
+
+func TestIncidentUnknownTarget(t *testing.T) {
+	targets := incidentTargets
+	incidentTargets = []incidentTarget{{server: "nowhere", to: "#test"}}
+	t.Cleanup(func() { incidentTargets = targets })
+
+	deliverIncident(1, db.Incident{
+		Severity: SeverityError, Server: "nowhere", Message: "unknown target",
+	})
+
+	queued := false
+	_, _, err := db.FlushOutbox("nowhere", func(entry db.OutboxEntry) bool {
+		queued = true
+		return false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued {
+		t.Fatal("queued an incident for a server that doesnt exist")
+	}
+}
+
+func TestMessage(t *testing.T) {
+	server := irctest.NewTestServer(t)
+	client := addTestServer(t, server, "message", "#test")
+
+	conn := server.Accept(t)
+	conn.ExpectRegistered(t)
+	waitFor(t, "connected", client.Connected)
+	waitFor(t, "mask", func() bool {
+		_, host := client.mask()
+		return host != ""
+	})
+
+	conn.Privmsg("msgid=abc", "alice", "#test", "hello there")
+
+	var msg *Message
+	select {
+	case msg = <-testMessages:
+	case <-time.After(irctest.TIMEOUT):
+		t.Fatal("timed out waiting for message")
+	}
+
+	if msg.ID != "abc" || msg.Sender != "alice" ||
+		msg.Where != "#test" || msg.Message != "hello there" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+
+	msg.Reply("hi alice")
+	conn.Expect(t,
+		`^@\+draft/reply=abc :mikogo!mikogo@irctest\.host PRIVMSG #test :hi alice$`,
+	)
+
+	// direct messages are answered to the sender
+	conn.Privmsg("", "alice", "mikogo", "psst")
+
+	select {
+	case msg = <-testMessages:
+	case <-time.After(irctest.TIMEOUT):
+		t.Fatal("timed out waiting for message")
+	}
+
+	if msg.Where != "alice" || msg.ID != "" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+}
+
+func TestTagsNeedCap(t *testing.T) {
+	server := irctest.NewTestServer(t)
+	server.SetUnsupportedCaps("message-tags")
+	client := addTestServer(t, server, "tagsneedcap", "#test")
+
+	conn := server.Accept(t)
+	conn.Expect(t, `^CAP REQ :message-tags$`)
+	conn.Expect(t, `^CAP REQ :account-tag$`)
+	conn.Expect(t, `^CAP END$`)
+	conn.ExpectRegistered(t)
+	waitFor(t, "connected", client.Connected)
+	waitFor(t, "mask", func() bool {
+		_, host := client.mask()
+		return host != ""
+	})
+
+	conn.Privmsg("msgid=abc", "alice", "#test", "hello there")
+
+	var msg *Message
+	select {
+	case msg = <-testMessages:
+	case <-time.After(irctest.TIMEOUT):
+		t.Fatal("timed out waiting for message")
+	}
+
+	msg.Typing()()
+	msg.Ack("done")
+	conn.Expect(t, `^:mikogo!mikogo@irctest\.host PRIVMSG #test :done$`)
+
+	for _, line := range conn.Log() {
+		if strings.HasPrefix(line, "@") || strings.Contains(line, "TAGMSG") {
+			t.Fatalf("sent tags without message-tags: %q", line)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	server := irctest.NewTestServer(t)
+	client := addTestServer(t, server, "middleware")
+
+	conn := server.Accept(t)
+	conn.ExpectRegistered(t)
+	waitFor(t, "connected", client.Connected)
+
+	remove := client.Use(Middleware{
+		Name: "test",
+		Inbound: func(c *Client, line *Line) bool {
+			return line.Nick() != "spammer"
+		},
+		Outbound: func(c *Client, line *string) bool {
+			*line += " (filtered)"
+			return true
+		},
+	})
+	defer remove()
+
+	conn.Privmsg("", "spammer", "mikogo", "dropped")
+	conn.Privmsg("", "alice", "mikogo", "kept")
+
+	select {
+	case msg := <-testMessages:
+		if msg.Sender != "alice" {
+			t.Fatalf("expected message from alice, got %s", msg.Sender)
+		}
+	case <-time.After(irctest.TIMEOUT):
+		t.Fatal("timed out waiting for message")
+	}
+
+	client.Send("alice", "hi")
+	conn.Expect(t, `PRIVMSG alice :hi \(filtered\)$`)
+}
+
+func TestLag(t *testing.T) {
+	server := irctest.NewTestServer(t)
+	client := addTestServer(t, server, "lag")
+
+	conn := server.Accept(t)
+	conn.ExpectRegistered(t)
+	waitFor(t, "connected", client.Connected)
+
+	client.ping()
+	conn.Expect(t, `^PING `)
+
+	waitFor(t, "lag measured", func() bool {
+		return client.Stats().Lag > 0
+	})
+}
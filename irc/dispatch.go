@@ -0,0 +1,134 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/makinori/mikogo/env"
+	"golang.org/x/sync/semaphore"
+)
+
+// messages are handled serially per server and channel (or dm),
+// with a global limit on how many are handled at once
+
+// per server and channel
+const DISPATCH_MAX_QUEUED = 16
+
+// dropped if waiting longer than this.
+// var so tests can shorten it
+var DISPATCH_MAX_AGE = time.Second * 30
+
+type dispatchJob struct {
+	msg    *Message
+	queued time.Time
+}
+
+// queues are removed once drained, so each saturation episode
+// only reports once
+type dispatchQueue struct {
+	jobs []dispatchJob
+	// under dispatchQueuesMutex
+	reportedFull bool
+	// only touched by the queue's goroutine
+	reportedBusy bool
+	reportedOld  bool
+}
+
+var (
+	dispatchWorkers     = semaphore.NewWeighted(int64(max(env.WORKERS, 1)))
+	dispatchQueues      = map[string]*dispatchQueue{}
+	dispatchQueuesMutex = sync.Mutex{}
+)
+
+func dispatchKey(msg *Message) string {
+	return msg.Client.Name + " " + strings.ToLower(msg.Where)
+}
+
+func dispatch(msg *Message) {
+	key := dispatchKey(msg)
+
+	dispatchQueuesMutex.Lock()
+
+	queue, running := dispatchQueues[key]
+	if !running {
+		queue = &dispatchQueue{}
+		dispatchQueues[key] = queue
+	}
+
+	if len(queue.jobs) >= DISPATCH_MAX_QUEUED {
+		report := !queue.reportedFull
+		queue.reportedFull = true
+		dispatchQueuesMutex.Unlock()
+
+		if report {
+			msg.Client.slog().Warn("dispatch queue full", "where", msg.Where)
+			// not on the reader goroutine as it writes to the db
+			go ReportIncident(SeverityWarn, msg.Client.Name, fmt.Sprintf(
+				"dispatch queue full for %s. dropping messages", msg.Where,
+			))
+		}
+		return
+	}
+
+	queue.jobs = append(queue.jobs, dispatchJob{
+		msg: msg, queued: time.Now(),
+	})
+
+	dispatchQueuesMutex.Unlock()
+
+	if !running {
+		go runDispatchQueue(key, queue)
+	}
+}
+
+func runDispatchQueue(key string, queue *dispatchQueue) {
+	for {
+		dispatchQueuesMutex.Lock()
+		if len(queue.jobs) == 0 {
+			delete(dispatchQueues, key)
+			dispatchQueuesMutex.Unlock()
+			return
+		}
+		job := queue.jobs[0]
+		queue.jobs = queue.jobs[1:]
+		dispatchQueuesMutex.Unlock()
+
+		if !dispatchWorkers.TryAcquire(1) {
+			if !queue.reportedBusy {
+				queue.reportedBusy = true
+				// not holding up the queue as it writes to the db
+				go ReportIncident(SeverityWarn, job.msg.Client.Name, fmt.Sprintf(
+					"all %d workers busy. messages are waiting", env.WORKERS,
+				))
+			}
+			dispatchWorkers.Acquire(context.Background(), 1)
+		}
+
+		age := time.Since(job.queued)
+		if age > DISPATCH_MAX_AGE {
+			dispatchWorkers.Release(1)
+			slog.Warn("dropped old message",
+				"server", job.msg.Client.Name, "where", job.msg.Where,
+				"age", age,
+			)
+			if !queue.reportedOld {
+				queue.reportedOld = true
+				go ReportIncident(SeverityWarn, job.msg.Client.Name, fmt.Sprintf(
+					"dropped messages in %s that waited too long", job.msg.Where,
+				))
+			}
+			continue
+		}
+
+		handleDispatched(job.msg)
+	}
+}
+
+func handleDispatched(msg *Message) {
+	defer dispatchWorkers.Release(1)
+	GlobalHandleMessage(msg)
+}
@@ -0,0 +1,183 @@
+package irc
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// swaps in a handler that blocks until release is closed
+type dispatchTest struct {
+	client  *Client
+	release chan struct{}
+
+	mutex      sync.Mutex
+	running    int
+	maxRunning int
+	handled    map[string][]string
+}
+
+func newDispatchTest(t *testing.T, workers int64) *dispatchTest {
+	d := &dispatchTest{
+		client:  &Client{Name: "dispatch"},
+		release: make(chan struct{}),
+		handled: map[string][]string{},
+	}
+
+	prevHandler := GlobalHandleMessage
+	prevWorkers := dispatchWorkers
+	prevMaxAge := DISPATCH_MAX_AGE
+
+	dispatchWorkers = semaphore.NewWeighted(workers)
+	GlobalHandleMessage = d.handle
+
+	t.Cleanup(func() {
+		select {
+		case <-d.release:
+		default:
+			close(d.release)
+		}
+		// queue goroutines touch dispatchWorkers until theyre removed
+		waitFor(t, "queues to drain", func() bool {
+			dispatchQueuesMutex.Lock()
+			defer dispatchQueuesMutex.Unlock()
+			return len(dispatchQueues) == 0
+		})
+		GlobalHandleMessage = prevHandler
+		dispatchWorkers = prevWorkers
+		DISPATCH_MAX_AGE = prevMaxAge
+	})
+
+	return d
+}
+
+func (d *dispatchTest) handle(msg *Message) {
+	d.mutex.Lock()
+	d.running++
+	d.maxRunning = max(d.maxRunning, d.running)
+	d.mutex.Unlock()
+
+	<-d.release
+
+	d.mutex.Lock()
+	d.running--
+	d.handled[msg.Where] = append(d.handled[msg.Where], msg.Message)
+	d.mutex.Unlock()
+}
+
+func (d *dispatchTest) send(where string, message string) {
+	dispatch(&Message{Client: d.client, Where: where, Message: message})
+}
+
+func (d *dispatchTest) waitRunning(t *testing.T, n int) {
+	t.Helper()
+	waitFor(t, fmt.Sprintf("%d running", n), func() bool {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		return d.running == n
+	})
+}
+
+func (d *dispatchTest) waitHandled(t *testing.T, where string, n int) []string {
+	t.Helper()
+	var handled []string
+	waitFor(t, fmt.Sprintf("%d handled in %s", n, where), func() bool {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		handled = slices.Clone(d.handled[where])
+		return len(handled) >= n
+	})
+	return handled
+}
+
+func TestDispatchWorkers(t *testing.T) {
+	d := newDispatchTest(t, 2)
+
+	d.send("#a", "1")
+	d.send("#b", "1")
+	d.send("#c", "1")
+	for i := 2; i <= 5; i++ {
+		d.send("#a", fmt.Sprint(i))
+	}
+
+	d.waitRunning(t, 2)
+	time.Sleep(time.Millisecond * 100)
+
+	d.mutex.Lock()
+	maxRunning := d.maxRunning
+	d.mutex.Unlock()
+	if maxRunning != 2 {
+		t.Fatalf("expected at most 2 running, got %d", maxRunning)
+	}
+
+	close(d.release)
+
+	handled := d.waitHandled(t, "#a", 5)
+	if !slices.Equal(handled, []string{"1", "2", "3", "4", "5"}) {
+		t.Fatalf("expected messages in order, got %v", handled)
+	}
+	d.waitHandled(t, "#b", 1)
+	d.waitHandled(t, "#c", 1)
+
+	d.mutex.Lock()
+	maxRunning = d.maxRunning
+	d.mutex.Unlock()
+	if maxRunning > 2 {
+		t.Fatalf("expected at most 2 running, got %d", maxRunning)
+	}
+}
+
+func TestDispatchQueueFull(t *testing.T) {
+	d := newDispatchTest(t, 1)
+
+	// the first one leaves the queue once its handled
+	d.send("#a", "0")
+	d.waitRunning(t, 1)
+
+	for i := 1; i <= DISPATCH_MAX_QUEUED+4; i++ {
+		d.send("#a", fmt.Sprint(i))
+	}
+
+	close(d.release)
+
+	handled := d.waitHandled(t, "#a", DISPATCH_MAX_QUEUED+1)
+	time.Sleep(time.Millisecond * 100)
+
+	d.mutex.Lock()
+	handled = slices.Clone(d.handled["#a"])
+	d.mutex.Unlock()
+
+	if len(handled) != DISPATCH_MAX_QUEUED+1 {
+		t.Fatalf(
+			"expected %d handled, got %d", DISPATCH_MAX_QUEUED+1, len(handled),
+		)
+	}
+	if handled[len(handled)-1] != fmt.Sprint(DISPATCH_MAX_QUEUED) {
+		t.Fatalf("expected newest messages dropped, got %v", handled)
+	}
+}
+
+func TestDispatchTooOld(t *testing.T) {
+	d := newDispatchTest(t, 1)
+	DISPATCH_MAX_AGE = time.Millisecond * 50
+
+	d.send("#a", "1")
+	d.waitRunning(t, 1)
+	// waits for the worker behind #a
+	d.send("#b", "old")
+
+	time.Sleep(DISPATCH_MAX_AGE * 2)
+	close(d.release)
+
+	d.waitHandled(t, "#a", 1)
+	d.send("#b", "new")
+
+	handled := d.waitHandled(t, "#b", 1)
+	if !slices.Equal(handled, []string{"new"}) {
+		t.Fatalf("expected old message dropped, got %v", handled)
+	}
+}
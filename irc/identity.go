@@ -0,0 +1,69 @@
+package irc
+
+import (
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/env"
+)
+
+type Identity struct {
+	Nick     string
+	Ident    string
+	Realname string
+	Pass     string
+	// empty sets both bot modes +b and +B
+	Modes string
+}
+
+func identityFromServer(server db.Server) Identity {
+	identity := Identity{
+		Nick:     server.Nick,
+		Ident:    server.Ident,
+		Realname: server.Realname,
+		Pass:     server.Pass,
+		Modes:    server.Modes,
+	}
+	if identity.Nick == "" {
+		identity.Nick = env.NICK
+	}
+	if identity.Ident == "" {
+		identity.Ident = identity.Nick
+	}
+	if identity.Realname == "" {
+		identity.Realname = identity.Nick
+	}
+	return identity
+}
+
+func (c *Client) register() {
+	// cap end is sent once these are answered
+	c.requestCaps()
+
+	identity := c.getIdentity()
+
+	// trailing so spaces and a leading colon arrive intact
+	if identity.Pass != "" {
+		c.writef("PASS :%s\r\n", identity.Pass)
+	}
+
+	c.setNick(identity.Nick)
+	c.writef("NICK %s\r\n", identity.Nick)
+	c.writef("USER %s 0 * :%s\r\n",
+		identity.Ident, identity.Realname,
+	)
+}
+
+// after 001 as our nick might have changed during registration
+func (c *Client) welcome() {
+	identity := c.getIdentity()
+	nick := c.Nick()
+	if identity.Modes != "" {
+		c.writef("MODE %s %s\r\n", nick, identity.Modes)
+	} else {
+		// bot mode b or B
+		c.writef("MODE %s +b\r\n", nick)
+		c.writef("MODE %s +B\r\n", nick)
+	}
+
+	// self whois for privmsg prefix
+	c.writef("WHOIS %s\r\n", nick)
+}
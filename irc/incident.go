@@ -1,34 +1,414 @@
 package irc
 
 import (
+	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/makinori/mikogo/db"
 	"github.com/makinori/mikogo/env"
 	"github.com/makinori/mikogo/ircf"
 )
 
 // > insert funny reimu image here
 
-func ReportIncident(msg string) {
-	homeClient := clients["home"]
-	if homeClient == nil {
-		slog.Error(
-			"failed to find home client whilst reporting incident",
-			"msg", msg,
-		)
+type Severity = uint8
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarn
+	SeverityError
+	SeverityCritical
+
+	// repeats within this window are counted on the same incident
+	INCIDENT_DEDUP_WINDOW = time.Hour
+	// repeats are only delivered again after this long
+	INCIDENT_REPEAT_DELAY = time.Minute * 15
+	// across all incidents per window, the rest are sent as a digest
+	INCIDENT_MAX_PER_WINDOW = 5
+	INCIDENT_RETENTION      = time.Hour * 24 * 30
+)
+
+// var so tests can shorten it
+var INCIDENT_RATE_WINDOW = time.Minute
+
+var severityNames = []string{"info", "warn", "error", "critical"}
+
+var severityColors = []uint8{98, 41, 40, 52}
+
+func SeverityName(severity Severity) string {
+	if int(severity) < len(severityNames) {
+		return severityNames[severity]
+	}
+	return fmt.Sprintf("unknown: %d", severity)
+}
+
+func ParseSeverity(name string) (Severity, bool) {
+	i := slices.Index(severityNames, strings.ToLower(name))
+	if i == -1 {
+		return 0, false
+	}
+	return Severity(i), true
+}
+
+func FormatSeverity(severity Severity) string {
+	color := uint8(40)
+	if int(severity) < len(severityColors) {
+		color = severityColors[severity]
+	}
+	return ircf.Bold().Color(98, color).Format(SeverityName(severity))
+}
+
+type incidentTarget struct {
+	server   string
+	to       string
+	severity Severity // minimum
+}
+
+// server:target[:severity] comma separated.
+// defaults to the owner on home
+func parseIncidentTargets(value string) []incidentTarget {
+	if strings.TrimSpace(value) == "" {
+		return []incidentTarget{{server: "home", to: env.OWNER}}
+	}
+
+	var targets []incidentTarget
+	for entry := range strings.SplitSeq(value, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			slog.Warn("invalid incident target", "target", entry)
+			continue
+		}
+
+		target := incidentTarget{server: parts[0], to: parts[1]}
+		if len(parts) > 2 {
+			severity, ok := ParseSeverity(parts[2])
+			if !ok {
+				slog.Warn("invalid incident target severity", "target", entry)
+				continue
+			}
+			target.severity = severity
+		}
+
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+var (
+	incidentTargets = parseIncidentTargets(env.INCIDENT_TARGETS)
+
+	incidentMutex      = sync.Mutex{}
+	incidentDeliveries []time.Time
+
+	// recent incidents by what they're about, so reports dont scan the db.
+	// loaded on first report
+	recentIncidents map[recentIncidentKey]recentIncident
+	// held back whilst rate limited, sent as a digest once the window reopens
+	heldIncidents      []heldIncident
+	heldIncidentsTimer *time.Timer
+)
+
+type recentIncidentKey struct {
+	severity Severity
+	server   string
+	msg      string
+}
+
+type recentIncident struct {
+	id   uint64
+	last time.Time
+}
+
+type heldIncident struct {
+	id       uint64
+	severity Severity
+}
+
+// also prunes incidents past retention
+func loadRecentIncidents(now time.Time) {
+	recentIncidents = map[recentIncidentKey]recentIncident{}
+
+	incidents, err := db.Incidents.GetAll()
+	if err != nil {
+		slog.Error("failed to get incidents", "err", err)
 		return
 	}
 
-	if !homeClient.active {
-		slog.Error(
-			"home client not active whilst reporting incident",
-			"msg", msg,
+	for key, incident := range incidents.AllFromFront() {
+		if now.Sub(incident.Last) > INCIDENT_RETENTION {
+			err = db.Incidents.Delete(key)
+			if err != nil {
+				slog.Error("failed to prune incident", "err", err)
+			}
+			continue
+		}
+
+		if incident.Acked || now.Sub(incident.Last) > INCIDENT_DEDUP_WINDOW {
+			continue
+		}
+
+		id, err := strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		recentIncidents[recentIncidentKey{
+			incident.Severity, incident.Server, incident.Message,
+		}] = recentIncident{id: id, last: incident.Last}
+	}
+}
+
+func findDuplicateIncident(
+	severity Severity, server string, msg string, now time.Time,
+) (uint64, db.Incident, bool) {
+	if recentIncidents == nil {
+		loadRecentIncidents(now)
+	}
+
+	key := recentIncidentKey{severity, server, msg}
+	recent, ok := recentIncidents[key]
+	if !ok {
+		return 0, db.Incident{}, false
+	}
+	if now.Sub(recent.last) > INCIDENT_DEDUP_WINDOW {
+		delete(recentIncidents, key)
+		return 0, db.Incident{}, false
+	}
+
+	// could have been acked or pruned since
+	incident, err, exists := db.Incidents.Get(db.IncidentKey(recent.id))
+	if err != nil {
+		slog.Error("failed to get incident", "err", err)
+		return 0, db.Incident{}, false
+	}
+	if !exists || incident.Acked {
+		delete(recentIncidents, key)
+		return 0, db.Incident{}, false
+	}
+
+	return recent.id, incident, true
+}
+
+// returns when the window reopens if not allowed
+func allowIncidentDelivery(now time.Time) (bool, time.Duration) {
+	incidentDeliveries = slices.DeleteFunc(incidentDeliveries,
+		func(t time.Time) bool {
+			return now.Sub(t) >= INCIDENT_RATE_WINDOW
+		},
+	)
+	if len(incidentDeliveries) >= INCIDENT_MAX_PER_WINDOW {
+		return false, incidentDeliveries[0].Add(INCIDENT_RATE_WINDOW).Sub(now)
+	}
+	incidentDeliveries = append(incidentDeliveries, now)
+	return true, 0
+}
+
+func pruneIncidents() {
+	incidentMutex.Lock()
+	defer incidentMutex.Unlock()
+	loadRecentIncidents(time.Now())
+}
+
+func init() {
+	go func() {
+		for {
+			time.Sleep(time.Hour)
+			pruneIncidents()
+		}
+	}()
+}
+
+func FormatIncident(id uint64, incident db.Incident) string {
+	out := fmt.Sprintf("#%d %s on %s: %s",
+		id,
+		FormatSeverity(incident.Severity),
+		ircf.BoldWhite.Format(incident.Server),
+		incident.Message,
+	)
+	if incident.Count > 1 {
+		out += ircf.Color(98).Format(fmt.Sprintf(" (x%d)", incident.Count))
+	}
+	return out
+}
+
+// never expires so targets always find out eventually
+func deliverIncident(id uint64, incident db.Incident) {
+	deliverToTargets(func(target incidentTarget) string {
+		if incident.Severity < target.severity {
+			return ""
+		}
+		return FormatIncident(id, incident)
+	})
+}
+
+const incidentDigestMaxIDs = 10
+
+// for incidents held back whilst rate limited
+func deliverIncidentDigest(held []heldIncident) {
+	deliverToTargets(func(target incidentTarget) string {
+		ids := []string{}
+		for _, incident := range held {
+			if incident.severity >= target.severity {
+				ids = append(ids, fmt.Sprintf("#%d", incident.id))
+			}
+		}
+		if len(ids) == 0 {
+			return ""
+		}
+
+		out := fmt.Sprintf("%d more whilst rate limited: ", len(ids))
+		if len(ids) > incidentDigestMaxIDs {
+			out += strings.Join(ids[:incidentDigestMaxIDs], " ") +
+				ircf.Color(98).Format(fmt.Sprintf(
+					" and %d more", len(ids)-incidentDigestMaxIDs,
+				))
+		} else {
+			out += strings.Join(ids, " ")
+		}
+		return out
+	})
+}
+
+// format returns empty to skip a target
+func deliverToTargets(format func(target incidentTarget) string) {
+	for _, target := range incidentTargets {
+		msg := format(target)
+		if msg == "" {
+			continue
+		}
+		formatted := ircf.Color(98, 40).Bold().Format("incident") + " " + msg
+
+		client := GetClient(target.server)
+		if client == nil {
+			// not synced yet is fine, but nothing would flush a queue
+			// for a server that doesnt exist
+			_, err, exists := db.Servers.Get(target.server)
+			if err != nil || !exists {
+				slog.Warn(
+					"incident target server not found. dropped incident",
+					"server", target.server, "err", err,
+				)
+				continue
+			}
+		}
+		if client == nil || !client.isActive() {
+			slog.Warn(
+				"incident target not available. queued incident",
+				"server", target.server,
+			)
+			queueOutbox(target.server, nil, target.to, formatted, time.Time{})
+			continue
+		}
+
+		client.send(nil, target.to, formatted, time.Time{})
+	}
+}
+
+func ReportIncident(severity Severity, server string, msg string) {
+	slog.Info("incident",
+		"severity", SeverityName(severity), "server", server, "msg", msg,
+	)
+	metricIncidents.Inc(SeverityName(severity))
+
+	incidentMutex.Lock()
+	defer incidentMutex.Unlock()
+
+	now := time.Now()
+
+	id, incident, found := findDuplicateIncident(severity, server, msg, now)
+	if !found {
+		incident = db.Incident{
+			Severity: severity,
+			Server:   server,
+			Message:  msg,
+			First:    now,
+			Last:     now,
+			Count:    1,
+		}
+	}
+
+	deliver := !found || now.Sub(incident.LastDelivered) >= INCIDENT_REPEAT_DELAY
+	hold := false
+	if deliver {
+		var reopens time.Duration
+		deliver, reopens = allowIncidentDelivery(now)
+		if !deliver {
+			hold = true
+			scheduleIncidentDigest(reopens)
+		}
+	}
+	if deliver {
+		incident.LastDelivered = now
+	}
+
+	var err error
+	if found {
+		// on the stored record so an ack since finding it isnt undone
+		err = db.Incidents.Update(db.IncidentKey(id),
+			func(stored *db.Incident) error {
+				stored.Count++
+				stored.Last = now
+				if deliver {
+					stored.LastDelivered = now
+				}
+				incident = *stored
+				return nil
+			},
 		)
+	} else {
+		id, err = db.AddIncident(incident)
+	}
+	if err != nil {
+		slog.Error("failed to store incident", "err", err)
 		return
 	}
 
-	slog.Info("incident", "msg", msg)
-	homeClient.Send(env.OWNER,
-		ircf.Color(98, 40).Bold().Format("incident")+": "+msg,
-	)
+	recentIncidents[recentIncidentKey{severity, server, msg}] =
+		recentIncident{id: id, last: now}
+
+	if hold {
+		slog.Warn("too many incidents. holding for digest", "server", server)
+		if !slices.ContainsFunc(heldIncidents, func(held heldIncident) bool {
+			return held.id == id
+		}) {
+			heldIncidents = append(heldIncidents, heldIncident{id, severity})
+		}
+	}
+
+	if deliver {
+		deliverIncident(id, incident)
+	}
+}
+
+// expects incidentMutex to be locked
+func scheduleIncidentDigest(after time.Duration) {
+	if heldIncidentsTimer != nil {
+		return
+	}
+	heldIncidentsTimer = time.AfterFunc(after, func() {
+		incidentMutex.Lock()
+		defer incidentMutex.Unlock()
+
+		held := heldIncidents
+		heldIncidents = nil
+		heldIncidentsTimer = nil
+
+		if len(held) == 0 {
+			return
+		}
+
+		allowed, reopens := allowIncidentDelivery(time.Now())
+		if !allowed {
+			heldIncidents = held
+			scheduleIncidentDigest(reopens)
+			return
+		}
+
+		deliverIncidentDigest(held)
+	})
 }
@@ -0,0 +1,80 @@
+package irctest
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/env"
+)
+
+// the bot's home server and a temporary database, shared by every test
+// in a package. start it from TestMain
+type Home struct {
+	*Server
+
+	dir string
+	// irc.Sync, as irctest cant import irc
+	sync func() error
+
+	mutex sync.Mutex
+	conn  *Conn
+}
+
+func StartHome(name string, sync func() error) (*Home, error) {
+	dir, err := os.MkdirTemp("", name)
+	if err != nil {
+		return nil, err
+	}
+	env.DB_PATH = filepath.Join(dir, "data.db")
+
+	server, err := NewServer()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	env.HOME_SERVER = server.Addr
+
+	err = db.Init()
+	if err != nil {
+		server.Close()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return &Home{Server: server, dir: dir, sync: sync}, nil
+}
+
+// home connects on the first sync and stays connected
+func (h *Home) Conn(t testing.TB) *Conn {
+	t.Helper()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.conn == nil {
+		err := h.sync()
+		if err != nil {
+			t.Fatalf("failed to sync: %v", err)
+		}
+		h.conn = h.Accept(t)
+		h.conn.ExpectRegistered(t)
+	}
+	return h.conn
+}
+
+// after the test dropped the home connection
+func (h *Home) Reconnect(t testing.TB) *Conn {
+	t.Helper()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.conn = h.Accept(t)
+	h.conn.ExpectRegistered(t)
+	return h.conn
+}
+
+func (h *Home) Close() {
+	h.Server.Close()
+	db.Close()
+	os.RemoveAll(h.dir)
+}
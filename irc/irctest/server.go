@@ -0,0 +1,343 @@
+// scripted irc server for testing clients without running ergo.
+// handles just enough of registration, whois and joins for the bot,
+// and records every line it receives
+package irctest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	Name    = "irctest"
+	Host    = "irctest.host"
+	TIMEOUT = time.Second * 5
+)
+
+type Server struct {
+	Addr string
+
+	// nicks that get 433 during registration
+	TakenNicks []string
+	// caps that get NAK instead of ACK
+	UnsupportedCaps []string
+
+	listener net.Listener
+	conns    chan *Conn
+	mutex    sync.Mutex
+}
+
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour * 24),
+	}
+
+	der, err := x509.CreateCertificate(
+		rand.Reader, template, template, &key.PublicKey, key,
+	)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// listens with tls on a random localhost port, as the client only dials tls
+func NewServer() (*Server, error) {
+	cert, err := selfSignedCert()
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		listener: listener,
+		conns:    make(chan *Conn, 16),
+	}
+
+	go s.acceptLoop()
+
+	return s, nil
+}
+
+// closes when the test finishes
+func NewTestServer(t testing.TB) *Server {
+	t.Helper()
+	s, err := NewServer()
+	if err != nil {
+		t.Fatalf("failed to start irc server: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *Server) Close() {
+	s.listener.Close()
+}
+
+func (s *Server) acceptLoop() {
+	for {
+		netConn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		conn := &Conn{
+			server:   s,
+			conn:     netConn,
+			received: make(chan string, 1024),
+		}
+		go conn.readLoop()
+		s.conns <- conn
+	}
+}
+
+func (s *Server) isTaken(nick string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return slices.Contains(s.TakenNicks, nick)
+}
+
+func (s *Server) SetTakenNicks(nicks ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.TakenNicks = nicks
+}
+
+func (s *Server) isSupported(caps string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for capability := range strings.FieldsSeq(strings.TrimPrefix(caps, ":")) {
+		if slices.Contains(s.UnsupportedCaps, capability) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) SetUnsupportedCaps(caps ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.UnsupportedCaps = caps
+}
+
+// waits for the next client to connect
+func (s *Server) Accept(t testing.TB) *Conn {
+	t.Helper()
+	select {
+	case conn := <-s.conns:
+		return conn
+	case <-time.After(TIMEOUT):
+		t.Fatalf("timed out waiting for client to connect")
+		return nil
+	}
+}
+
+type Conn struct {
+	server *Server
+	conn   net.Conn
+
+	// lines from the client without \r\n
+	received chan string
+
+	mutex      sync.Mutex
+	log        []string
+	nick       string
+	user       string
+	gotUser    bool
+	inCap      bool
+	registered bool
+}
+
+func (c *Conn) Nick() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.nick
+}
+
+func (c *Conn) Mask() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.nick + "!" + c.user + "@" + Host
+}
+
+// every line received so far
+func (c *Conn) Log() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return slices.Clone(c.log)
+}
+
+func (c *Conn) Send(format string, a ...any) {
+	fmt.Fprintf(c.conn, format+"\r\n", a...)
+}
+
+func (c *Conn) Close() {
+	c.conn.Close()
+}
+
+func (c *Conn) readLoop() {
+	defer close(c.received)
+	reader := bufio.NewReader(c.conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		c.mutex.Lock()
+		c.log = append(c.log, line)
+		c.mutex.Unlock()
+
+		c.script(line)
+		c.received <- line
+	}
+}
+
+func (c *Conn) tryRegister() {
+	if c.registered || c.inCap || !c.gotUser || c.nick == "" {
+		return
+	}
+	c.registered = true
+	c.Send(":%s 001 %s :welcome to irctest", Name, c.nick)
+}
+
+// replies like a real server would
+func (c *Conn) script(line string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// drop tags, we only care about commands
+	if strings.HasPrefix(line, "@") {
+		_, line, _ = strings.Cut(line, " ")
+	}
+
+	command, params, _ := strings.Cut(line, " ")
+
+	switch strings.ToUpper(command) {
+	case "CAP":
+		sub, caps, _ := strings.Cut(params, " ")
+		switch sub {
+		case "REQ":
+			c.inCap = true
+			// all or nothing like the spec says
+			if c.server.isSupported(caps) {
+				c.Send(":%s CAP * ACK %s", Name, caps)
+			} else {
+				c.Send(":%s CAP * NAK %s", Name, caps)
+			}
+		case "END":
+			c.inCap = false
+			c.tryRegister()
+		}
+
+	case "NICK":
+		if !c.registered && c.server.isTaken(params) {
+			c.Send(":%s 433 * %s :Nickname is already in use", Name, params)
+			return
+		}
+		c.nick = params
+		c.tryRegister()
+
+	case "USER":
+		c.user, _, _ = strings.Cut(params, " ")
+		c.gotUser = true
+		c.tryRegister()
+
+	case "WHOIS":
+		c.Send(":%s 311 %s %s %s %s * :%s",
+			Name, c.nick, params, c.user, Host, params,
+		)
+		c.Send(":%s 318 %s %s :End of WHOIS", Name, c.nick, params)
+
+	case "JOIN":
+		c.Send(":%s!%s@%s JOIN %s", c.nick, c.user, Host, params)
+
+	case "PART":
+		c.Send(":%s!%s@%s PART %s", c.nick, c.user, Host, params)
+
+	case "PING":
+		c.Send(":%s PONG %s %s", Name, Name, params)
+	}
+}
+
+// waits for a line matching the regexp and returns the submatches.
+// lines before it are skipped
+func (c *Conn) Expect(t testing.TB, pattern string) []string {
+	t.Helper()
+
+	re := regexp.MustCompile(pattern)
+	timeout := time.After(TIMEOUT)
+
+	for {
+		select {
+		case line, ok := <-c.received:
+			if !ok {
+				t.Fatalf("connection closed waiting for %q", pattern)
+				return nil
+			}
+			matches := re.FindStringSubmatch(line)
+			if matches != nil {
+				return matches
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %q", pattern)
+			return nil
+		}
+	}
+}
+
+// waits for the client to register and returns its nick
+func (c *Conn) ExpectRegistered(t testing.TB) string {
+	t.Helper()
+	c.Expect(t, `^WHOIS `)
+	return c.Nick()
+}
+
+// tags should be formatted without the @
+func (c *Conn) Privmsg(tags string, from string, to string, msg string) {
+	prefix := ""
+	if tags != "" {
+		prefix = "@" + tags + " "
+	}
+	c.Send("%s:%s!user@%s PRIVMSG %s :%s", prefix, from, Host, to, msg)
+}
+
+func (c *Conn) Kick(by string, channel string, nick string, reason string) {
+	c.Send(":%s!user@%s KICK %s %s :%s", by, Host, channel, nick, reason)
+}
+
+func (c *Conn) Invite(by string, nick string, channel string) {
+	c.Send(":%s!user@%s INVITE %s %s", by, Host, nick, channel)
+}
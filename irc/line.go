@@ -0,0 +1,89 @@
+package irc
+
+import (
+	"strings"
+)
+
+// https://modern.ircdocs.horse/#messages
+
+type Line struct {
+	Tags    Tags
+	Source  string // without the colon
+	Command string // upper case
+	Params  []string
+	// last param started with a colon
+	trailing bool
+}
+
+func ParseLine(raw string) *Line {
+	line := &Line{}
+
+	var rest string
+	line.Tags, rest = splitTags(strings.TrimRight(raw, "\r\n"))
+
+	if strings.HasPrefix(rest, ":") {
+		line.Source, rest, _ = strings.Cut(rest[1:], " ")
+		rest = strings.TrimLeft(rest, " ")
+	}
+
+	for rest != "" {
+		if line.Command != "" && strings.HasPrefix(rest, ":") {
+			line.Params = append(line.Params, rest[1:])
+			line.trailing = true
+			break
+		}
+
+		var param string
+		param, rest, _ = strings.Cut(rest, " ")
+		rest = strings.TrimLeft(rest, " ")
+
+		if line.Command == "" {
+			line.Command = strings.ToUpper(param)
+		} else {
+			line.Params = append(line.Params, param)
+		}
+	}
+
+	return line
+}
+
+func (l *Line) Param(i int) string {
+	if i < len(l.Params) {
+		return l.Params[i]
+	}
+	return ""
+}
+
+// nick from source
+func (l *Line) Nick() string {
+	nick, _, _ := strings.Cut(l.Source, "!")
+	return nick
+}
+
+func (l *Line) format(withTags bool) string {
+	var out strings.Builder
+
+	if withTags {
+		out.WriteString(l.Tags.prefix())
+	}
+	if l.Source != "" {
+		out.WriteString(":" + l.Source + " ")
+	}
+	out.WriteString(l.Command)
+
+	for i, param := range l.Params {
+		out.WriteByte(' ')
+		if i == len(l.Params)-1 && (l.trailing || param == "" ||
+			strings.Contains(param, " ") || strings.HasPrefix(param, ":")) {
+			out.WriteByte(':')
+		}
+		out.WriteString(param)
+	}
+
+	return out.String()
+}
+
+// without \r\n
+func (l *Line) String() string {
+	return l.format(true)
+}
@@ -0,0 +1,59 @@
+package irc
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	line := ParseLine(
+		"@msgid=abc;+draft/reply=a\\sb :alice!a@host PRIVMSG #test :hi there\r\n",
+	)
+
+	if line.Tags["msgid"] != "abc" || line.Tags["+draft/reply"] != "a b" {
+		t.Fatalf("unexpected tags: %v", line.Tags)
+	}
+	if line.Source != "alice!a@host" || line.Nick() != "alice" {
+		t.Fatalf("unexpected source: %s", line.Source)
+	}
+	if line.Command != "PRIVMSG" {
+		t.Fatalf("unexpected command: %s", line.Command)
+	}
+	if !slices.Equal(line.Params, []string{"#test", "hi there"}) {
+		t.Fatalf("unexpected params: %q", line.Params)
+	}
+}
+
+func TestLineString(t *testing.T) {
+	for _, raw := range []string{
+		":irc 001 mikogo :welcome",
+		":op!u@h KICK #test mikogo :bye",
+		"PRIVMSG #test :hi",
+		"JOIN #test",
+		"@+typing=active TAGMSG #test",
+		":irc 433 * mikogo :Nickname is already in use",
+	} {
+		if out := ParseLine(raw + "\r\n").String(); out != raw {
+			t.Errorf("expected %q, got %q", raw, out)
+		}
+	}
+}
+
+func TestRedactLine(t *testing.T) {
+	for in, expected := range map[string]string{
+		"PASS hunter2":                       "PASS <redacted>",
+		"PRIVMSG NickServ :IDENTIFY hunter2": "PRIVMSG NickServ :IDENTIFY <redacted>",
+		"AUTHENTICATE aHVudGVyMg==":          "AUTHENTICATE <redacted>",
+		"OPER mikogo hunter2":                "OPER mikogo <redacted>",
+		"PRIVMSG #test :PASS is fine here":   "PRIVMSG #test :PASS is fine here",
+
+		":maki!maki@host PRIVMSG mikogo :m!server set pass home hunter 2": ":maki!maki@host PRIVMSG mikogo :m!server set pass home <redacted>",
+		"PRIVMSG mikogo :server  set pass home hunter2":                   "PRIVMSG mikogo :server  set pass home <redacted>",
+		"PRIVMSG mikogo :m!server set pass home":                          "PRIVMSG mikogo :m!server set pass home",
+		"PRIVMSG maki :  secret: abc123":                                  "PRIVMSG maki :  secret: <redacted>",
+	} {
+		if out := redactLine(in); out != expected {
+			t.Errorf("expected %q, got %q", expected, out)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package irc
+
+type Message struct {
+	Client *Client
+	// msgid tag. empty if the server doesnt support message-tags
+	ID     string
+	Tags   Tags
+	Sender string
+	// nick!user@host
+	Mask string
+	// account-tag. empty if not logged in or unsupported
+	Account string
+	Where   string
+	Message string
+}
+
+var GlobalHandleMessage func(msg *Message)
+
+// https://ircv3.net/specs/client-tags/reply
+
+func (m *Message) replyTags() Tags {
+	if m.ID == "" || !m.Client.hasCap("message-tags") {
+		return nil
+	}
+	return Tags{"+draft/reply": m.ID}
+}
+
+// sends to where the message came from, threaded if possible
+func (m *Message) Reply(msg string) {
+	m.Client.SendTagged(m.replyTags(), m.Where, msg)
+}
+
+// https://ircv3.net/specs/client-tags/react
+
+// returns false if the message can't be reacted to
+func (m *Message) React(reaction string) bool {
+	if m.ID == "" {
+		return false
+	}
+	return m.Client.SendTagmsg(Tags{
+		"+draft/react": reaction,
+		"+draft/reply": m.ID,
+	}, m.Where)
+}
+
+// reacts with a checkmark or replies with text if reactions arent available
+func (m *Message) Ack(fallback string) {
+	if m.React("✅") {
+		return
+	}
+	m.Reply(fallback)
+}
+
+// https://ircv3.net/specs/client-tags/typing
+
+// use with defer, e.g. defer msg.Typing()()
+func (m *Message) Typing() (done func()) {
+	m.Client.SendTagmsg(Tags{"+typing": "active"}, m.Where)
+	return func() {
+		m.Client.SendTagmsg(Tags{"+typing": "done"}, m.Where)
+	}
+}
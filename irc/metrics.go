@@ -0,0 +1,130 @@
+package irc
+
+import (
+	"log/slog"
+
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/metrics"
+)
+
+var metricIncidents = metrics.NewCounter(
+	"mikogo_incidents_total", "Incidents reported by severity.", "severity",
+)
+
+func collectClients(fn func(c *Client) float64) func() []metrics.Sample {
+	return func() []metrics.Sample {
+		var samples []metrics.Sample
+		for _, client := range Clients() {
+			samples = append(samples, metrics.Sample{
+				Labels: metrics.Labels{"server", client.Name},
+				Value:  fn(client),
+			})
+		}
+		return samples
+	}
+}
+
+func collectStats(fn func(stats Stats) float64) func() []metrics.Sample {
+	return collectClients(func(c *Client) float64 {
+		return fn(c.Stats())
+	})
+}
+
+func init() {
+	metrics.NewGaugeFunc(
+		"mikogo_server_connected", "Whether the server is connected and registered.",
+		collectClients(func(c *Client) float64 {
+			if c.Connected() {
+				return 1
+			}
+			return 0
+		}),
+	)
+
+	metrics.NewGaugeFunc(
+		"mikogo_server_state", "Connection state of each server.",
+		func() []metrics.Sample {
+			var samples []metrics.Sample
+			for _, client := range Clients() {
+				current := client.StateName()
+				for _, state := range []string{
+					"connecting", "connected", "disconnected",
+				} {
+					value := 0.0
+					if state == current {
+						value = 1
+					}
+					samples = append(samples, metrics.Sample{
+						Labels: metrics.Labels{
+							"server", client.Name, "state", state,
+						},
+						Value: value,
+					})
+				}
+			}
+			return samples
+		},
+	)
+
+	metrics.NewGaugeFunc(
+		"mikogo_server_lag_seconds", "Round trip of the last ping.",
+		collectStats(func(stats Stats) float64 {
+			return stats.Lag.Seconds()
+		}),
+	)
+
+	metrics.NewCounterFunc(
+		"mikogo_server_connects_total", "Successful registrations.",
+		collectStats(func(stats Stats) float64 {
+			return float64(stats.Connects)
+		}),
+	)
+
+	metrics.NewCounterFunc(
+		"mikogo_server_reconnects_total", "Registrations after the first.",
+		collectStats(func(stats Stats) float64 {
+			return float64(stats.Reconnects)
+		}),
+	)
+
+	metrics.NewCounterFunc(
+		"mikogo_server_lines_received_total", "Lines read from the server.",
+		collectStats(func(stats Stats) float64 {
+			return float64(stats.LinesIn)
+		}),
+	)
+
+	metrics.NewCounterFunc(
+		"mikogo_server_lines_sent_total", "Lines written to the server.",
+		collectStats(func(stats Stats) float64 {
+			return float64(stats.LinesOut)
+		}),
+	)
+
+	metrics.NewCounterFunc(
+		"mikogo_server_received_bytes_total", "Bytes read from the server.",
+		collectStats(func(stats Stats) float64 {
+			return float64(stats.BytesIn)
+		}),
+	)
+
+	metrics.NewCounterFunc(
+		"mikogo_server_sent_bytes_total", "Bytes written to the server.",
+		collectStats(func(stats Stats) float64 {
+			return float64(stats.BytesOut)
+		}),
+	)
+
+	metrics.NewGaugeFunc(
+		"mikogo_server_outbox_messages", "Messages waiting to be sent.",
+		collectClients(func(c *Client) float64 {
+			n, err := db.OutboxLen(c.Name)
+			if err != nil {
+				slog.Error("failed to get outbox length",
+					"server", c.Name, "err", err,
+				)
+			}
+			return float64(n)
+		}),
+	)
+}
@@ -0,0 +1,88 @@
+package irc
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// runs in order, globals first then per client.
+// either function can be nil
+type Middleware struct {
+	Name string
+	// on parsed lines before they're handled. can modify the line.
+	// return false to drop it
+	Inbound func(c *Client, line *Line) bool
+	// on lines without \r\n before they're written. can modify the line.
+	// return false to drop it
+	Outbound func(c *Client, line *string) bool
+}
+
+type middlewares struct {
+	entries []*Middleware
+	mutex   sync.RWMutex
+}
+
+// returns a function to remove it again
+func (m *middlewares) use(middleware Middleware) (remove func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry := &middleware
+	m.entries = append(m.entries, entry)
+
+	return func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		m.entries = slices.DeleteFunc(m.entries, func(e *Middleware) bool {
+			return e == entry
+		})
+	}
+}
+
+func (m *middlewares) get() []*Middleware {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return slices.Clone(m.entries)
+}
+
+var globalMiddlewares = &middlewares{}
+
+// for all clients
+func Use(middleware Middleware) (remove func()) {
+	return globalMiddlewares.use(middleware)
+}
+
+func (c *Client) Use(middleware Middleware) (remove func()) {
+	return c.middlewares.use(middleware)
+}
+
+func (c *Client) allMiddlewares() []*Middleware {
+	return slices.Concat(globalMiddlewares.get(), c.middlewares.get())
+}
+
+func (c *Client) runInbound(line *Line) bool {
+	for _, middleware := range c.allMiddlewares() {
+		if middleware.Inbound != nil && !middleware.Inbound(c, line) {
+			return false
+		}
+	}
+	return true
+}
+
+// line should end with \r\n
+func (c *Client) runOutbound(line string) (string, bool) {
+	all := c.allMiddlewares()
+	if len(all) == 0 {
+		return line, true
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	for _, middleware := range all {
+		if middleware.Outbound != nil && !middleware.Outbound(c, &line) {
+			return "", false
+		}
+	}
+
+	return line + "\r\n", true
+}
@@ -0,0 +1,51 @@
+package irc
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/makinori/mikogo/db"
+)
+
+func queueOutbox(server string, tags Tags, to, msg string, expires time.Time) {
+	err := db.PushOutbox(server, db.OutboxEntry{
+		Tags:    tags,
+		To:      to,
+		Message: msg,
+		Expires: expires,
+	})
+	if err != nil {
+		slog.Error(
+			"failed to queue message", "server", server, "to", to, "err", err,
+		)
+	}
+}
+
+// zero expires never expires
+func (c *Client) send(tags Tags, to, msg string, expires time.Time) {
+	// wait for flushing to finish so messages stay in order
+	c.outboxMutex.RLock()
+	defer c.outboxMutex.RUnlock()
+
+	if c.Connected() && c.writePrivmsg(tags, to, msg) {
+		return
+	}
+
+	c.slog().Info("queued message whilst disconnected", "to", to)
+	queueOutbox(c.Name, tags, to, msg, expires)
+}
+
+// outboxMutex has to be held
+func (c *Client) flushOutbox() {
+	sent, expired, err := db.FlushOutbox(c.Name,
+		func(entry db.OutboxEntry) bool {
+			return c.writePrivmsg(entry.Tags, entry.To, entry.Message)
+		},
+	)
+	if err != nil {
+		c.slog().Error("failed to flush outbox", "err", err)
+	}
+	if sent > 0 || expired > 0 {
+		c.slog().Info("flushed outbox", "sent", sent, "expired", expired)
+	}
+}
@@ -22,6 +22,7 @@ func init() {
 		for {
 			time.Sleep(time.Second * 60)
 			pingAllClients()
+			saveAllStats()
 		}
 	}()
 }
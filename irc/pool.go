@@ -3,6 +3,7 @@ package irc
 import (
 	"log/slog"
 	"slices"
+	"strings"
 	"sync"
 
 	"github.com/makinori/mikogo/db"
@@ -19,6 +20,21 @@ func GetClient(name string) *Client {
 	return clients[name]
 }
 
+// sorted by name
+func Clients() []*Client {
+	clientsMutex.RLock()
+	defer clientsMutex.RUnlock()
+
+	out := make([]*Client, 0, len(clients))
+	for _, client := range clients {
+		out = append(out, client)
+	}
+	slices.SortFunc(out, func(a, b *Client) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return out
+}
+
 func Sync() error {
 	servers, err := db.Servers.GetAll()
 	if err != nil {
@@ -39,12 +55,12 @@ func Sync() error {
 			allServerNames = append(allServerNames, name)
 
 			if clients[name] == nil {
-				clients[name] = newClient(server.Address)
+				clients[name] = newClient(name, server)
 			}
 
 			clients[name].setTargetChannels(server.Channels)
 
-			if !clients[name].active {
+			if !clients[name].isActive() {
 				clients[name].init()
 			} else {
 				go clients[name].SyncChannels()
@@ -56,7 +72,12 @@ func Sync() error {
 		for name := range clients {
 			if !slices.Contains(allServerNames, name) {
 				clients[name].delete()
-				clients[name] = nil
+				delete(clients, name)
+
+				err := db.DeleteOutbox(name)
+				if err != nil {
+					slog.Error("failed to delete outbox", "name", name, "err", err)
+				}
 			}
 		}
 	})()
@@ -64,7 +85,7 @@ func Sync() error {
 	clientsMutex.RLock()
 	defer clientsMutex.RUnlock()
 
-	// then reconnect those that got a new address last
+	// then reconnect those that lost their address or got a new identity last
 	// as we dont want to accidentally connect twice anywhere
 
 	for name, server := range servers.AllFromBack() {
@@ -74,21 +95,28 @@ func Sync() error {
 			continue
 		}
 
-		if client.Address == server.Address {
-			continue
+		from := client.Address()
+		addressChanged := client.setAddresses(server.Addresses)
+		if addressChanged {
+			slog.Info(
+				"server address changed", "name", name,
+				"from", from, "to", client.Address(),
+			)
 		}
 
-		slog.Info(
-			"server address changed", "name", name,
-			"from", client.Address, "to", server.Address,
-		)
+		identityChanged := client.setIdentity(identityFromServer(server))
+		if identityChanged {
+			slog.Info("server identity changed", "name", name)
+		}
 
-		client.Address = server.Address
+		if !addressChanged && !identityChanged {
+			continue
+		}
 
 		// only run reconnect if the client is connected
 		// new address will be used regardless
 
-		if client.state == ConnStateConnected {
+		if client.getState() == ConnStateConnected {
 			client.reconnect()
 		}
 	}
@@ -0,0 +1,100 @@
+package irc
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/env"
+)
+
+// kept across reconnects
+type Stats struct {
+	db.ServerStats
+	// zero if not connected
+	ConnectedAt time.Time
+	LastError   string
+	LastErrorAt time.Time
+	// round trip of the last ping. zero until the first pong
+	Lag time.Duration
+
+	pingSent time.Time
+	// connected before in this process. persisted connects dont count,
+	// so a restart isnt counted as a reconnect
+	connectedBefore bool
+}
+
+func (c *Client) Stats() Stats {
+	c.statsMutex.Lock()
+	defer c.statsMutex.Unlock()
+	return c.stats
+}
+
+func (c *Client) updateStats(fn func(stats *Stats)) {
+	c.statsMutex.Lock()
+	defer c.statsMutex.Unlock()
+	fn(&c.stats)
+}
+
+func (c *Client) statsError(err string) {
+	c.updateStats(func(stats *Stats) {
+		stats.LastError = err
+		stats.LastErrorAt = time.Now()
+	})
+}
+
+func (c *Client) handlePong() {
+	c.updateStats(func(stats *Stats) {
+		if stats.pingSent.IsZero() {
+			return
+		}
+		stats.Lag = time.Since(stats.pingSent)
+		stats.pingSent = time.Time{}
+	})
+}
+
+// called by the command handler
+func (c *Client) CountCommand() {
+	c.updateStats(func(stats *Stats) {
+		stats.Commands++
+	})
+}
+
+func loadStats(name string) Stats {
+	stats := Stats{}
+	stats.Since = time.Now()
+
+	if !env.PERSIST_STATS {
+		return stats
+	}
+
+	stored, err, exists := db.Stats.Get(name)
+	if err != nil {
+		slog.Error("failed to get stats", "server", name, "err", err)
+	}
+	if exists {
+		stats.ServerStats = stored
+	}
+
+	return stats
+}
+
+func (c *Client) saveStats() {
+	if !env.PERSIST_STATS {
+		return
+	}
+
+	err := db.Stats.Put(c.Name, c.Stats().ServerStats)
+	if err != nil {
+		c.slog().Error("failed to save stats", "err", err)
+	}
+}
+
+func saveAllStats() {
+	clientsMutex.RLock()
+	defer clientsMutex.RUnlock()
+
+	for _, client := range clients {
+		client.saveStats()
+	}
+}
@@ -0,0 +1,89 @@
+package irc
+
+import (
+	"slices"
+	"strings"
+)
+
+// https://ircv3.net/specs/extensions/message-tags
+
+type Tags map[string]string
+
+var tagValueEscaper = strings.NewReplacer(
+	`\`, `\\`, ";", `\:`, " ", `\s`, "\r", `\r`, "\n", `\n`,
+)
+
+func unescapeTagValue(value string) string {
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			out.WriteByte(value[i])
+			continue
+		}
+		i++
+		if i >= len(value) {
+			break // trailing backslash is dropped
+		}
+		switch value[i] {
+		case ':':
+			out.WriteByte(';')
+		case 's':
+			out.WriteByte(' ')
+		case 'r':
+			out.WriteByte('\r')
+		case 'n':
+			out.WriteByte('\n')
+		default:
+			out.WriteByte(value[i])
+		}
+	}
+	return out.String()
+}
+
+func parseTags(raw string) Tags {
+	tags := Tags{}
+	for tag := range strings.SplitSeq(raw, ";") {
+		if tag == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(tag, "=")
+		tags[key] = unescapeTagValue(value)
+	}
+	return tags
+}
+
+// splits "@tags :prefix COMMAND" into tags and the rest of the line
+func splitTags(line string) (Tags, string) {
+	if !strings.HasPrefix(line, "@") {
+		return Tags{}, line
+	}
+	raw, rest, _ := strings.Cut(line[1:], " ")
+	return parseTags(raw), strings.TrimLeft(rest, " ")
+}
+
+// sorted so output is stable
+func (t Tags) String() string {
+	keys := make([]string, 0, len(t))
+	for key := range t {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	out := make([]string, len(keys))
+	for i, key := range keys {
+		if t[key] == "" {
+			out[i] = key
+		} else {
+			out[i] = key + "=" + tagValueEscaper.Replace(t[key])
+		}
+	}
+	return strings.Join(out, ";")
+}
+
+// with trailing space or empty if no tags
+func (t Tags) prefix() string {
+	if len(t) == 0 {
+		return ""
+	}
+	return "@" + t.String() + " "
+}
@@ -0,0 +1,105 @@
+package irc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/makinori/mikogo/env"
+)
+
+// writes raw traffic for a server to a file with secrets redacted
+
+var traceRedactions = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)^((?:@\S+ )?(?::\S+ )?PASS ).+$`),
+	regexp.MustCompile(`(?i)^((?:@\S+ )?(?::\S+ )?AUTHENTICATE ).+$`),
+	regexp.MustCompile(`(?i)^((?:@\S+ )?(?::\S+ )?OPER \S+ ).+$`),
+	regexp.MustCompile(
+		`(?i)^((?:@\S+ )?(?::\S+ )?PRIVMSG NickServ :(?:IDENTIFY|REGISTER) ).+$`,
+	),
+	regexp.MustCompile(`(?i)^((?:@\S+ )?(?::\S+ )?(?:NS|NICKSERV) (?:IDENTIFY|REGISTER) ).+$`),
+	// our own admin commands and replies
+	regexp.MustCompile(
+		`(?i)^((?:@\S+ )?(?::\S+ )?PRIVMSG \S+ :.*?\bserver\s+set\s+pass\s+\S+\s+).+$`,
+	),
+	regexp.MustCompile(`(?i)^((?:@\S+ )?(?::\S+ )?PRIVMSG \S+ :\s*secret: ).+$`),
+}
+
+func redactLine(line string) string {
+	for _, re := range traceRedactions {
+		if re.MatchString(line) {
+			return re.ReplaceAllString(line, "${1}<redacted>")
+		}
+	}
+	return line
+}
+
+type tracer struct {
+	file   *os.File
+	mutex  sync.Mutex
+	remove func()
+}
+
+func (t *tracer) write(direction string, line string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	fmt.Fprintf(t.file, "%s %s %s\n",
+		time.Now().Format(time.RFC3339Nano), direction, redactLine(line),
+	)
+}
+
+func (c *Client) TracePath() string {
+	return filepath.Join(env.TRACE_DIR, "trace-"+c.Name+".log")
+}
+
+func (c *Client) Tracing() bool {
+	c.traceMutex.Lock()
+	defer c.traceMutex.Unlock()
+	return c.trace != nil
+}
+
+// appends to the trace file if it already exists
+func (c *Client) SetTrace(on bool) error {
+	c.traceMutex.Lock()
+	defer c.traceMutex.Unlock()
+
+	if on == (c.trace != nil) {
+		return nil
+	}
+
+	if !on {
+		c.trace.remove()
+		err := c.trace.file.Close()
+		c.trace = nil
+		c.slog().Info("stopped tracing")
+		return err
+	}
+
+	file, err := os.OpenFile(
+		c.TracePath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600,
+	)
+	if err != nil {
+		return err
+	}
+
+	t := &tracer{file: file}
+	t.remove = c.Use(Middleware{
+		Name: "trace",
+		Inbound: func(c *Client, line *Line) bool {
+			t.write("<", line.String())
+			return true
+		},
+		Outbound: func(c *Client, line *string) bool {
+			t.write(">", *line)
+			return true
+		},
+	})
+	c.trace = t
+
+	c.slog().Info("started tracing", "path", c.TracePath())
+
+	return nil
+}
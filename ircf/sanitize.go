@@ -0,0 +1,26 @@
+package ircf
+
+import (
+	"strings"
+	"unicode"
+)
+
+// for text from outside like feeds and webhooks. removes formatting and
+// control characters, collapses whitespace so newlines cant add lines,
+// and cuts it to max runes
+func Sanitize(msg string, max int) string {
+	msg = Strip(msg)
+	msg = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, msg)
+	msg = strings.Join(strings.Fields(msg), " ")
+
+	runes := []rune(msg)
+	if max > 0 && len(runes) > max {
+		return strings.TrimSpace(string(runes[:max-1])) + "…"
+	}
+	return msg
+}
@@ -0,0 +1,12 @@
+package ircf
+
+import "regexp"
+
+var formattingRegexp = regexp.MustCompile(
+	"[\x02\x0f\x11\x16\x1d\x1e\x1f]|\x03(\\d{1,2}(,\\d{1,2})?)?|\x04([0-9a-fA-F]{6}(,[0-9a-fA-F]{6})?)?",
+)
+
+// removes formatting for showing outside of irc
+func Strip(msg string) string {
+	return formattingRegexp.ReplaceAllString(msg, "")
+}
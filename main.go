@@ -1,9 +1,14 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/makinori/mikogo/command"
 	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/feed"
 	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/web"
 )
 
 func handleMessage(msg *irc.Message) {
@@ -12,15 +17,42 @@ func handleMessage(msg *irc.Message) {
 	command.Run(msg)
 }
 
+// usage: mikogo healthcheck [healthz|readyz]
+func healthcheck() {
+	path := "readyz"
+	if len(os.Args) > 2 {
+		path = os.Args[2]
+	}
+
+	err := web.Healthcheck(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unhealthy:", err)
+		os.Exit(1)
+	}
+}
+
 func main() {
+	// before opening the db as the running bot has it locked
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		healthcheck()
+		return
+	}
+
 	err := db.Init()
 	if err != nil {
 		panic(err)
 	}
 
 	irc.GlobalHandleMessage = handleMessage
+	irc.Use(command.IgnoreMiddleware)
 
 	irc.Sync()
+	feed.Start()
+
+	err = web.Start()
+	if err != nil {
+		panic(err)
+	}
 
 	keepAlive := make(chan struct{})
 	<-keepAlive
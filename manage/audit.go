@@ -0,0 +1,31 @@
+package manage
+
+import (
+	"log/slog"
+
+	"github.com/makinori/mikogo/db"
+)
+
+type Actor struct {
+	// where the action came from, e.g. a server name or http
+	Server string
+	// nick or remote address
+	Sender string
+}
+
+func Audit(actor Actor, action string, detail string) {
+	slog.Info("audit",
+		"server", actor.Server, "sender", actor.Sender,
+		"action", action, "detail", detail,
+	)
+
+	err := db.AddAudit(db.AuditEntry{
+		Server: actor.Server,
+		Sender: actor.Sender,
+		Action: action,
+		Detail: detail,
+	})
+	if err != nil {
+		slog.Error("failed to add audit entry", "err", err)
+	}
+}
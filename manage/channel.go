@@ -0,0 +1,83 @@
+package manage
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/irc"
+)
+
+// adds # if missing
+func NormalizeChannel(channel string) string {
+	if !strings.HasPrefix(channel, "#") {
+		channel = "#" + channel
+	}
+	return channel
+}
+
+func validateChannel(channel string) error {
+	if channel == "#" || strings.ContainsAny(channel, " ,\x07\r\n") {
+		return invalid("invalid channel name")
+	}
+	return nil
+}
+
+// returns the normalized channel
+func JoinChannel(name string, channel string) (string, error) {
+	channel = NormalizeChannel(channel)
+	err := validateChannel(channel)
+	if err != nil {
+		return channel, err
+	}
+
+	err = updateServer(name, func(server *db.Server) error {
+		if slices.Contains(server.Channels, channel) {
+			return conflict("already in channel")
+		}
+		server.Channels = append(server.Channels, channel)
+		return nil
+	})
+	if err != nil {
+		return channel, err
+	}
+
+	irc.Sync()
+
+	return channel, nil
+}
+
+// returns the normalized channel
+func LeaveChannel(name string, channel string) (string, error) {
+	channel = NormalizeChannel(channel)
+
+	err := updateServer(name, func(server *db.Server) error {
+		i := slices.Index(server.Channels, channel)
+		if i == -1 {
+			return notFound("not in channel")
+		}
+		server.Channels = slices.Delete(server.Channels, i, i+1)
+		return nil
+	})
+	if err != nil {
+		return channel, err
+	}
+
+	irc.Sync()
+
+	return channel, nil
+}
+
+func SyncChannels(name string) error {
+	client := irc.GetClient(name)
+	if client == nil {
+		return notFound("server not found")
+	}
+	if !client.Connected() {
+		return conflict("server not connected")
+	}
+
+	go client.SyncChannels()
+
+	return nil
+}
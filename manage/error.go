@@ -0,0 +1,40 @@
+// server and channel management shared by admin commands and the http api.
+// errors are meant to be shown to whoever asked
+package manage
+
+import "fmt"
+
+type ErrorKind uint8
+
+const (
+	ErrorInvalid ErrorKind = iota
+	ErrorNotFound
+	ErrorConflict
+	ErrorInternal
+)
+
+type Error struct {
+	Kind ErrorKind
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+func invalid(msg string) error {
+	return &Error{Kind: ErrorInvalid, Msg: msg}
+}
+
+func notFound(msg string) error {
+	return &Error{Kind: ErrorNotFound, Msg: msg}
+}
+
+func conflict(msg string) error {
+	return &Error{Kind: ErrorConflict, Msg: msg}
+}
+
+// e.g. internal("failed to get", err)
+func internal(msg string, err error) error {
+	return &Error{Kind: ErrorInternal, Msg: fmt.Sprintf("%s: %v", msg, err)}
+}
@@ -0,0 +1,52 @@
+package manage
+
+import (
+	"errors"
+
+	"github.com/makinori/mikogo/db"
+)
+
+// in one transaction so a concurrent report cant undo it
+func AckIncident(id uint64) error {
+	err := db.Incidents.Update(db.IncidentKey(id), func(incident *db.Incident) error {
+		incident.Acked = true
+		return nil
+	})
+	if errors.Is(err, db.ErrNotFound) {
+		return notFound("incident not found")
+	}
+	if err != nil {
+		return internal("failed to update", err)
+	}
+	return nil
+}
+
+// returns how many werent acked yet
+func AckAllIncidents() (int, error) {
+	incidents, err := db.Incidents.GetAll()
+	if err != nil {
+		return 0, internal("failed to get all", err)
+	}
+
+	acked := 0
+	for key, incident := range incidents.AllFromFront() {
+		if incident.Acked {
+			continue
+		}
+
+		err = db.Incidents.Update(key, func(incident *db.Incident) error {
+			incident.Acked = true
+			return nil
+		})
+		if errors.Is(err, db.ErrNotFound) {
+			// pruned since
+			continue
+		}
+		if err != nil {
+			return acked, internal("failed to update", err)
+		}
+		acked++
+	}
+
+	return acked, nil
+}
@@ -0,0 +1,249 @@
+package manage
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/irc"
+)
+
+type ChannelInfo struct {
+	Name   string `json:"name"`
+	Joined bool   `json:"joined"`
+}
+
+type ServerInfo struct {
+	Name      string   `json:"name"`
+	Addresses []string `json:"addresses"`
+	// empty if not connected
+	Address     string        `json:"address"`
+	Nick        string        `json:"nick,omitempty"`
+	State       string        `json:"state"`
+	Connected   bool          `json:"connected"`
+	ConnectedAt time.Time     `json:"connected_at,omitzero"`
+	Reconnects  uint64        `json:"reconnects"`
+	Channels    []ChannelInfo `json:"channels"`
+}
+
+func ListServers() ([]ServerInfo, error) {
+	servers, err := db.Servers.GetAll()
+	if err != nil {
+		return nil, internal("failed to get all", err)
+	}
+
+	out := []ServerInfo{}
+	for name, server := range servers.AllFromBack() {
+		info := ServerInfo{
+			Name:      name,
+			Addresses: slices.Concat(server.Addresses),
+			Nick:      server.Nick,
+			State:     "disconnected",
+			Channels:  []ChannelInfo{},
+		}
+
+		// might not be synced yet
+		client := irc.GetClient(name)
+		var currentChannels []string
+		if client != nil {
+			currentChannels = client.CurrentChannels()
+			info.State = client.StateName()
+			info.Connected = client.Connected()
+			if info.Connected {
+				info.Address = client.Address()
+			}
+			stats := client.Stats()
+			info.ConnectedAt = stats.ConnectedAt
+			info.Reconnects = stats.Reconnects
+		}
+
+		for _, channel := range server.Channels {
+			info.Channels = append(info.Channels, ChannelInfo{
+				Name:   channel,
+				Joined: slices.Contains(currentChannels, channel),
+			})
+		}
+
+		out = append(out, info)
+	}
+
+	return out, nil
+}
+
+func validateName(name string) error {
+	if name == "" || strings.ContainsFunc(name, func(r rune) bool {
+		return r <= ' ' || r == '/'
+	}) {
+		return invalid("invalid server name")
+	}
+	return nil
+}
+
+// and that no other server uses them
+func validateAddresses(name string, addresses []string) error {
+	if len(addresses) == 0 {
+		return invalid("need at least one address")
+	}
+
+	for i, address := range addresses {
+		_, port, err := net.SplitHostPort(address)
+		if err != nil || port == "" {
+			return invalid("invalid address, expected host:port: " + address)
+		}
+
+		if slices.Contains(addresses[:i], address) {
+			return invalid("duplicate address: " + address)
+		}
+
+		serverName, _, err, ok := db.GetServerByAddress(address)
+		if err != nil {
+			return internal("failed to get server by address", err)
+		}
+		if ok && serverName != name {
+			return conflict("server with same address already exists: " + serverName)
+		}
+	}
+
+	return nil
+}
+
+func hasControl(value string) bool {
+	return strings.ContainsFunc(value, unicode.IsControl)
+}
+
+// values end up in NICK, USER and such, so nothing that would break the line
+func validateIdentity(server db.Server) error {
+	for field, value := range map[string]string{
+		"nick": server.Nick, "ident": server.Ident,
+	} {
+		if hasControl(value) || strings.Contains(value, " ") ||
+			strings.HasPrefix(value, ":") || strings.HasPrefix(value, "#") {
+			return invalid("invalid " + field)
+		}
+	}
+
+	for field, value := range map[string]string{
+		"realname": server.Realname, "pass": server.Pass, "modes": server.Modes,
+	} {
+		if hasControl(value) {
+			return invalid("invalid " + field)
+		}
+	}
+
+	return nil
+}
+
+func getServer(name string) (db.Server, error) {
+	server, err, exists := db.Servers.Get(name)
+	if err != nil {
+		return server, internal("failed to get", err)
+	}
+	if !exists {
+		return server, notFound("server not found")
+	}
+	return server, nil
+}
+
+// edits in one transaction so a concurrent update, like the client saving
+// its last address, isnt lost. errors from update are returned as is
+func updateServer(name string, update func(server *db.Server) error) error {
+	err := db.Servers.Update(name, update)
+	if errors.Is(err, db.ErrNotFound) {
+		return notFound("server not found")
+	}
+	var manageErr *Error
+	if err != nil && !errors.As(err, &manageErr) {
+		return internal("failed to update", err)
+	}
+	return err
+}
+
+func AddServer(name string, addresses []string) error {
+	if name == "home" {
+		return invalid("cannot add home server")
+	}
+
+	err := validateName(name)
+	if err != nil {
+		return err
+	}
+
+	err = validateAddresses(name, addresses)
+	if err != nil {
+		return err
+	}
+
+	err = db.Servers.Add(name, db.Server{Addresses: addresses})
+	if errors.Is(err, db.ErrExists) {
+		return conflict("server already exists")
+	}
+	if err != nil {
+		return internal("failed to add", err)
+	}
+
+	irc.Sync()
+
+	return nil
+}
+
+func RemoveServer(name string) error {
+	if name == "home" {
+		return invalid("cannot remove home server")
+	}
+
+	_, err := getServer(name)
+	if err != nil {
+		return err
+	}
+
+	err = db.Servers.Delete(name)
+	if err != nil {
+		return internal("failed to remove", err)
+	}
+
+	irc.Sync()
+
+	return nil
+}
+
+func SetServerAddresses(name string, addresses []string) error {
+	if name == "home" {
+		return invalid("cannot update home server address")
+	}
+
+	err := validateAddresses(name, addresses)
+	if err != nil {
+		return err
+	}
+
+	err = updateServer(name, func(server *db.Server) error {
+		server.Addresses = addresses
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	irc.Sync()
+
+	return nil
+}
+
+// for nick, ident and such. will reconnect
+func UpdateServer(name string, update func(server *db.Server)) error {
+	err := updateServer(name, func(server *db.Server) error {
+		update(server)
+		return validateIdentity(*server)
+	})
+	if err != nil {
+		return err
+	}
+
+	irc.Sync()
+
+	return nil
+}
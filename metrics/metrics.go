@@ -0,0 +1,255 @@
+// prometheus text format without the client library.
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// pairs of label name and value
+type Labels []string
+
+type Sample struct {
+	Labels Labels
+	Value  float64
+}
+
+type family interface {
+	write(w io.Writer)
+}
+
+var (
+	families      []family
+	familiesMutex = sync.Mutex{}
+)
+
+func register(f family) {
+	familiesMutex.Lock()
+	defer familiesMutex.Unlock()
+	families = append(families, f)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels Labels, extra ...string) string {
+	all := slices.Concat(labels, extra)
+	if len(all) == 0 {
+		return ""
+	}
+	out := make([]string, 0, len(all)/2)
+	for i := 0; i+1 < len(all); i += 2 {
+		out = append(out, all[i]+`="`+labelValueEscaper.Replace(all[i+1])+`"`)
+	}
+	return "{" + strings.Join(out, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func writeHeader(w io.Writer, name string, help string, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// label values joined so they can be used as a map key
+func labelsKey(names []string, values []string) (string, Labels) {
+	labels := make(Labels, 0, len(names)*2)
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		labels = append(labels, name, value)
+	}
+	return strings.Join(values, "\x00"), labels
+}
+
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+
+	values map[string]*Sample
+	mutex  sync.Mutex
+}
+
+func NewCounter(name string, help string, labels ...string) *Counter {
+	c := &Counter{
+		name: name, help: help, labels: labels,
+		values: map[string]*Sample{},
+	}
+	register(c)
+	return c
+}
+
+// label values in the same order as declared
+func (c *Counter) Add(value float64, labelValues ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key, labels := labelsKey(c.labels, labelValues)
+	sample, ok := c.values[key]
+	if !ok {
+		sample = &Sample{Labels: labels}
+		c.values[key] = sample
+	}
+	sample.Value += value
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	writeHeader(w, c.name, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		sample := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n",
+			c.name, formatLabels(sample.Labels), formatValue(sample.Value),
+		)
+	}
+}
+
+var DefaultBuckets = []float64{
+	0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30,
+}
+
+type histogramValue struct {
+	labels Labels
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	values map[string]*histogramValue
+	mutex  sync.Mutex
+}
+
+func NewHistogram(
+	name string, help string, buckets []float64, labels ...string,
+) *Histogram {
+	h := &Histogram{
+		name: name, help: help, labels: labels,
+		buckets: slices.Sorted(slices.Values(buckets)),
+		values:  map[string]*histogramValue{},
+	}
+	register(h)
+	return h
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	key, labels := labelsKey(h.labels, labelValues)
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{
+			labels: labels, counts: make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = v
+	}
+
+	for i, bucket := range h.buckets {
+		if value <= bucket {
+			v.counts[i]++
+			break
+		}
+	}
+	v.count++
+	v.sum += value
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	writeHeader(w, h.name, h.help, "histogram")
+	for _, key := range sortedKeys(h.values) {
+		v := h.values[key]
+
+		cumulative := uint64(0)
+		for i, bucket := range h.buckets {
+			cumulative += v.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n",
+				h.name, formatLabels(v.labels, "le", formatValue(bucket)),
+				cumulative,
+			)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n",
+			h.name, formatLabels(v.labels, "le", "+Inf"), v.count,
+		)
+		fmt.Fprintf(w, "%s_sum%s %s\n",
+			h.name, formatLabels(v.labels), formatValue(v.sum),
+		)
+		fmt.Fprintf(w, "%s_count%s %d\n",
+			h.name, formatLabels(v.labels), v.count,
+		)
+	}
+}
+
+// for values read when scraped
+type collector struct {
+	name    string
+	help    string
+	kind    string
+	collect func() []Sample
+}
+
+func (c *collector) write(w io.Writer) {
+	writeHeader(w, c.name, c.help, c.kind)
+	for _, sample := range c.collect() {
+		fmt.Fprintf(w, "%s%s %s\n",
+			c.name, formatLabels(sample.Labels), formatValue(sample.Value),
+		)
+	}
+}
+
+func NewGaugeFunc(name string, help string, collect func() []Sample) {
+	register(&collector{name: name, help: help, kind: "gauge", collect: collect})
+}
+
+// for counters kept elsewhere, like client stats
+func NewCounterFunc(name string, help string, collect func() []Sample) {
+	register(&collector{name: name, help: help, kind: "counter", collect: collect})
+}
+
+func WriteAll(w io.Writer) {
+	familiesMutex.Lock()
+	all := slices.Clone(families)
+	familiesMutex.Unlock()
+
+	for _, f := range all {
+		f.write(w)
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
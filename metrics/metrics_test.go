@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	families = nil
+
+	counter := NewCounter("test_total", "a counter", "name")
+	counter.Inc("a")
+	counter.Add(2, `quote"d`)
+
+	histogram := NewHistogram("test_seconds", "a histogram", []float64{1, 0.1})
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+
+	NewGaugeFunc("test_gauge", "a gauge", func() []Sample {
+		return []Sample{{Labels: Labels{"server", "home"}, Value: 1}}
+	})
+
+	var out strings.Builder
+	WriteAll(&out)
+
+	expected := `# HELP test_total a counter
+# TYPE test_total counter
+test_total{name="a"} 1
+test_total{name="quote\"d"} 2
+# HELP test_seconds a histogram
+# TYPE test_seconds histogram
+test_seconds_bucket{le="0.1"} 1
+test_seconds_bucket{le="1"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 5.55
+test_seconds_count 3
+# HELP test_gauge a gauge
+# TYPE test_gauge gauge
+test_gauge{server="home"} 1
+`
+
+	if out.String() != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
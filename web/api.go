@@ -0,0 +1,204 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/makinori/mikogo/env"
+	"github.com/makinori/mikogo/manage"
+)
+
+// same operations as the server and channel admin commands
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	var manageErr *manage.Error
+	if errors.As(err, &manageErr) {
+		switch manageErr.Kind {
+		case manage.ErrorInvalid:
+			status = http.StatusBadRequest
+		case manage.ErrorNotFound:
+			status = http.StatusNotFound
+		case manage.ErrorConflict:
+			status = http.StatusConflict
+		}
+	}
+
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
+
+func validToken(token string) bool {
+	return env.API_TOKEN != "" && subtle.ConstantTimeCompare(
+		[]byte(token), []byte(env.API_TOKEN),
+	) == 1
+}
+
+func requireToken(handle http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if env.API_TOKEN == "" {
+			writeJSON(w, http.StatusNotFound, apiError{Error: "api disabled"})
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !validToken(token) {
+			writeJSON(w, http.StatusUnauthorized, apiError{Error: "unauthorized"})
+			return
+		}
+
+		handle(w, r)
+	}
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(v)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{
+			Error: "invalid json: " + err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+func actor(r *http.Request) manage.Actor {
+	return manage.Actor{Server: "http", Sender: r.RemoteAddr}
+}
+
+type ok struct {
+	OK bool `json:"ok"`
+}
+
+func handleAPIServerList(w http.ResponseWriter, r *http.Request) {
+	servers, err := manage.ListServers()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, servers)
+}
+
+func handleAPIServerAdd(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name      string   `json:"name"`
+		Addresses []string `json:"addresses"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	err := manage.AddServer(body.Name, body.Addresses)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	manage.Audit(actor(r), "server add", body.Name+" "+strings.Join(body.Addresses, ","))
+	writeJSON(w, http.StatusCreated, ok{OK: true})
+}
+
+func handleAPIServerRemove(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	err := manage.RemoveServer(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	manage.Audit(actor(r), "server remove", name)
+	writeJSON(w, http.StatusOK, ok{OK: true})
+}
+
+func handleAPIServerSetAddr(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	var body struct {
+		Addresses []string `json:"addresses"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	err := manage.SetServerAddresses(name, body.Addresses)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	manage.Audit(actor(r), "server set addr", name+" "+strings.Join(body.Addresses, ","))
+	writeJSON(w, http.StatusOK, ok{OK: true})
+}
+
+func handleAPIChannelJoin(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	var body struct {
+		Channel string `json:"channel"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	channel, err := manage.JoinChannel(name, body.Channel)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	manage.Audit(actor(r), "channel join", name+" "+channel)
+	writeJSON(w, http.StatusCreated, ok{OK: true})
+}
+
+// channel can be given without the # so it doesnt need escaping
+func handleAPIChannelLeave(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	channel, err := manage.LeaveChannel(name, r.PathValue("channel"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	manage.Audit(actor(r), "channel leave", name+" "+channel)
+	writeJSON(w, http.StatusOK, ok{OK: true})
+}
+
+func handleAPIChannelSync(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	err := manage.SyncChannels(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, ok{OK: true})
+}
+
+func handleAPIStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, serverStatuses())
+}
+
+func init() {
+	for pattern, handle := range map[string]http.HandlerFunc{
+		"GET /api/status":                               handleAPIStatus,
+		"GET /api/servers":                              handleAPIServerList,
+		"POST /api/servers":                             handleAPIServerAdd,
+		"DELETE /api/servers/{name}":                    handleAPIServerRemove,
+		"PUT /api/servers/{name}/addresses":             handleAPIServerSetAddr,
+		"POST /api/servers/{name}/channels":             handleAPIChannelJoin,
+		"DELETE /api/servers/{name}/channels/{channel}": handleAPIChannelLeave,
+		"POST /api/servers/{name}/sync":                 handleAPIChannelSync,
+	} {
+		mux.HandleFunc(pattern, requireToken(handle))
+	}
+}
@@ -0,0 +1,158 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/makinori/mikogo/env"
+	"github.com/makinori/mikogo/irc/irctest"
+	"github.com/makinori/mikogo/manage"
+)
+
+const testToken = "hunter2"
+
+func apiRequest(
+	t *testing.T, method string, path string, token string, body any,
+) (int, []byte) {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, testServer.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var out bytes.Buffer
+	out.ReadFrom(res.Body)
+
+	return res.StatusCode, out.Bytes()
+}
+
+func TestAPIAuth(t *testing.T) {
+	env.API_TOKEN = ""
+	status, _ := apiRequest(t, "GET", "/api/servers", "", nil)
+	if status != http.StatusNotFound {
+		t.Fatalf("api should be disabled without a token, got %d", status)
+	}
+
+	env.API_TOKEN = testToken
+	defer func() { env.API_TOKEN = "" }()
+
+	status, _ = apiRequest(t, "GET", "/api/servers", "wrong", nil)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized, got %d", status)
+	}
+
+	status, _ = apiRequest(t, "GET", "/api/servers", testToken, nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected ok, got %d", status)
+	}
+}
+
+func TestAPIServers(t *testing.T) {
+	testHome.Conn(t)
+
+	env.API_TOKEN = testToken
+	defer func() { env.API_TOKEN = "" }()
+
+	server := irctest.NewTestServer(t)
+
+	status, body := apiRequest(t, "POST", "/api/servers", testToken, map[string]any{
+		"name": "api", "addresses": []string{server.Addr},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("failed to add: %d %s", status, body)
+	}
+	t.Cleanup(func() { manage.RemoveServer("api") })
+
+	conn := server.Accept(t)
+	conn.ExpectRegistered(t)
+
+	// same address twice
+	status, _ = apiRequest(t, "POST", "/api/servers", testToken, map[string]any{
+		"name": "api2", "addresses": []string{server.Addr},
+	})
+	if status != http.StatusConflict {
+		t.Fatalf("expected conflict, got %d", status)
+	}
+
+	status, _ = apiRequest(t, "POST", "/api/servers", testToken, map[string]any{
+		"name": "bad", "addresses": []string{"no port"},
+	})
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected bad request, got %d", status)
+	}
+
+	status, _ = apiRequest(t, "POST", "/api/servers", testToken, map[string]any{
+		"name": "dupe", "addresses": []string{"a.test:6697", "a.test:6697"},
+	})
+	if status != http.StatusBadRequest {
+		t.Fatalf("expected bad request for duplicate address, got %d", status)
+	}
+
+	status, body = apiRequest(t, "POST", "/api/servers/api/channels", testToken,
+		map[string]any{"channel": "test"},
+	)
+	if status != http.StatusCreated {
+		t.Fatalf("failed to join: %d %s", status, body)
+	}
+	conn.Expect(t, `^JOIN #test$`)
+
+	status, body = apiRequest(t, "GET", "/api/servers", testToken, nil)
+	if status != http.StatusOK {
+		t.Fatalf("failed to list: %d %s", status, body)
+	}
+
+	var servers []manage.ServerInfo
+	err := json.Unmarshal(body, &servers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, info := range servers {
+		if info.Name == "api" {
+			found = len(info.Channels) == 1 && info.Channels[0].Name == "#test"
+		}
+	}
+	if !found {
+		t.Fatalf("server or channel missing from list: %s", body)
+	}
+
+	status, _ = apiRequest(t, "DELETE", "/api/servers/api/channels/test", testToken, nil)
+	if status != http.StatusOK {
+		t.Fatalf("failed to leave: %d", status)
+	}
+	conn.Expect(t, `^PART #test$`)
+
+	status, _ = apiRequest(t, "DELETE", "/api/servers/home", testToken, nil)
+	if status != http.StatusBadRequest {
+		t.Fatalf("removed home server: %d", status)
+	}
+
+	status, _ = apiRequest(t, "DELETE", "/api/servers/api", testToken, nil)
+	if status != http.StatusOK {
+		t.Fatalf("failed to remove: %d", status)
+	}
+}
@@ -0,0 +1,373 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/makinori/mikogo/command"
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/env"
+	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/ircf"
+	"github.com/makinori/mikogo/manage"
+)
+
+// logs in with API_TOKEN. sessions are random ids kept in memory,
+// so restarting or changing the token logs everyone out
+
+const (
+	sessionCookie         = "mikogo_session"
+	sessionDuration       = time.Hour * 24 * 7
+	dashboardMaxIncidents = 15
+	dashboardMaxActivity  = 25
+
+	// failed logins per ip within the window before refusing more
+	loginMaxFailures   = 5
+	loginFailureWindow = time.Minute * 15
+)
+
+type session struct {
+	expires time.Time
+	// so changing the token ends the session
+	token string
+}
+
+var (
+	sessions      = map[string]session{}
+	sessionsMutex = sync.Mutex{}
+
+	loginFailures      = map[string][]time.Time{}
+	loginFailuresMutex = sync.Mutex{}
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+var templates = template.Must(
+	template.New("").Funcs(template.FuncMap{
+		"ago": func(t time.Time) string {
+			return time.Since(t).Round(time.Second).String() + " ago"
+		},
+		"strip":    ircf.Strip,
+		"severity": irc.SeverityName,
+	}).ParseFS(templatesFS, "templates/*.html"),
+)
+
+func tokenHash() string {
+	sum := sha256.Sum256([]byte(env.API_TOKEN))
+	return hex.EncodeToString(sum[:])
+}
+
+func newSession() (string, error) {
+	data := make([]byte, 32)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(data)
+
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	now := time.Now()
+	for id, session := range sessions {
+		if now.After(session.expires) {
+			delete(sessions, id)
+		}
+	}
+
+	sessions[id] = session{
+		expires: now.Add(sessionDuration),
+		token:   tokenHash(),
+	}
+
+	return id, nil
+}
+
+func endSession(id string) {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+	delete(sessions, id)
+}
+
+func loggedIn(r *http.Request) bool {
+	if env.API_TOKEN == "" {
+		return false
+	}
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	session, ok := sessions[cookie.Value]
+	if !ok {
+		return false
+	}
+	if time.Now().After(session.expires) || session.token != tokenHash() {
+		delete(sessions, cookie.Value)
+		return false
+	}
+	return true
+}
+
+func setSession(
+	w http.ResponseWriter, r *http.Request, value string, maxAge int,
+) {
+	// web.Start serves plain http, so only when behind tls.
+	// otherwise the browser drops it and login loops
+	secure := r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// also forgets old failures
+func tooManyLoginFailures(ip string) bool {
+	loginFailuresMutex.Lock()
+	defer loginFailuresMutex.Unlock()
+
+	now := time.Now()
+	for key, failures := range loginFailures {
+		failures = slices.DeleteFunc(failures, func(t time.Time) bool {
+			return now.Sub(t) > loginFailureWindow
+		})
+		if len(failures) == 0 {
+			delete(loginFailures, key)
+		} else {
+			loginFailures[key] = failures
+		}
+	}
+
+	return len(loginFailures[ip]) >= loginMaxFailures
+}
+
+func addLoginFailure(ip string) {
+	loginFailuresMutex.Lock()
+	defer loginFailuresMutex.Unlock()
+	loginFailures[ip] = append(loginFailures[ip], time.Now())
+}
+
+func render(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := templates.ExecuteTemplate(w, name, data)
+	if err != nil {
+		slog.Error("failed to render template", "name", name, "err", err)
+	}
+}
+
+func handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	render(w, "login.html", map[string]any{
+		"Disabled": env.API_TOKEN == "",
+		"Error":    r.URL.Query().Get("error"),
+	})
+}
+
+func handleLogin(w http.ResponseWriter, r *http.Request) {
+	ip := remoteIP(r)
+	if tooManyLoginFailures(ip) {
+		http.Redirect(w, r, "/login?error=too+many+attempts", http.StatusSeeOther)
+		return
+	}
+
+	if !validToken(r.PostFormValue("token")) {
+		addLoginFailure(ip)
+		manage.Audit(actor(r), "dashboard login failed", "")
+		http.Redirect(w, r, "/login?error=wrong+token", http.StatusSeeOther)
+		return
+	}
+
+	id, err := newSession()
+	if err != nil {
+		slog.Error("failed to create session", "err", err)
+		http.Redirect(w, r, "/login?error=failed+to+create+session", http.StatusSeeOther)
+		return
+	}
+
+	setSession(w, r, id, int(sessionDuration.Seconds()))
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err == nil {
+		endSession(cookie.Value)
+	}
+	setSession(w, r, "", -1)
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+func requireSession(handle http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !loggedIn(r) {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		handle(w, r)
+	}
+}
+
+type dashboardIncident struct {
+	ID uint64
+	db.Incident
+}
+
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	data := map[string]any{
+		"Error":    r.URL.Query().Get("error"),
+		"Done":     r.URL.Query().Get("done"),
+		"Commit":   env.GIT_COMMIT,
+		"Activity": command.RecentActivity(),
+	}
+
+	servers, err := manage.ListServers()
+	if err != nil {
+		data["Error"] = err.Error()
+	}
+	data["Servers"] = servers
+
+	incidents, err := db.Incidents.GetAll()
+	if err != nil {
+		data["Error"] = "failed to get incidents: " + err.Error()
+	} else {
+		shown := []dashboardIncident{}
+		for key, incident := range incidents.AllFromBack() {
+			if incident.Acked {
+				continue
+			}
+			if len(shown) == dashboardMaxIncidents {
+				break
+			}
+			id, _ := strconv.ParseUint(key, 10, 64)
+			shown = append(shown, dashboardIncident{ID: id, Incident: incident})
+		}
+		data["Incidents"] = shown
+	}
+
+	activity := data["Activity"].([]command.Activity)
+	data["Activity"] = activity[:min(len(activity), dashboardMaxActivity)]
+
+	render(w, "dashboard.html", data)
+}
+
+// redirects back to the dashboard with the result
+func dashboardAction(
+	action string, run func(r *http.Request) (detail string, err error),
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		detail, err := run(r)
+
+		query := url.Values{}
+		if err != nil {
+			query.Set("error", err.Error())
+		} else {
+			manage.Audit(actor(r), action, detail)
+			query.Set("done", strings.TrimSpace(action+" "+detail))
+		}
+
+		http.Redirect(w, r, "/dashboard?"+query.Encode(), http.StatusSeeOther)
+	}
+}
+
+func formAddresses(r *http.Request) []string {
+	return strings.FieldsFunc(r.PostFormValue("addresses"), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+func init() {
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+	})
+
+	mux.HandleFunc("GET /login", handleLoginPage)
+	mux.HandleFunc("POST /login", handleLogin)
+	mux.HandleFunc("POST /logout", handleLogout)
+
+	mux.HandleFunc("GET /dashboard", requireSession(handleDashboard))
+
+	for pattern, handle := range map[string]http.HandlerFunc{
+		"POST /dashboard/servers": dashboardAction("server add",
+			func(r *http.Request) (string, error) {
+				name := r.PostFormValue("name")
+				addresses := formAddresses(r)
+				return name, manage.AddServer(name, addresses)
+			},
+		),
+		"POST /dashboard/servers/{name}/remove": dashboardAction("server remove",
+			func(r *http.Request) (string, error) {
+				name := r.PathValue("name")
+				return name, manage.RemoveServer(name)
+			},
+		),
+		"POST /dashboard/servers/{name}/addresses": dashboardAction("server set addr",
+			func(r *http.Request) (string, error) {
+				name := r.PathValue("name")
+				addresses := formAddresses(r)
+				return name + " " + strings.Join(addresses, ","),
+					manage.SetServerAddresses(name, addresses)
+			},
+		),
+		"POST /dashboard/servers/{name}/join": dashboardAction("channel join",
+			func(r *http.Request) (string, error) {
+				name := r.PathValue("name")
+				channel, err := manage.JoinChannel(name, r.PostFormValue("channel"))
+				return name + " " + channel, err
+			},
+		),
+		"POST /dashboard/servers/{name}/leave": dashboardAction("channel leave",
+			func(r *http.Request) (string, error) {
+				name := r.PathValue("name")
+				channel, err := manage.LeaveChannel(name, r.PostFormValue("channel"))
+				return name + " " + channel, err
+			},
+		),
+		"POST /dashboard/servers/{name}/sync": dashboardAction("channel sync",
+			func(r *http.Request) (string, error) {
+				name := r.PathValue("name")
+				return name, manage.SyncChannels(name)
+			},
+		),
+		"POST /dashboard/incidents/{id}/ack": dashboardAction("incident ack",
+			func(r *http.Request) (string, error) {
+				id := r.PathValue("id")
+				n, err := strconv.ParseUint(id, 10, 64)
+				if err != nil {
+					return "#" + id, errors.New("invalid incident id")
+				}
+				return "#" + id, manage.AckIncident(n)
+			},
+		),
+	} {
+		mux.HandleFunc(pattern, requireSession(handle))
+	}
+}
@@ -0,0 +1,200 @@
+package web
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/makinori/mikogo/env"
+)
+
+var noRedirectClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func TestDashboardLogin(t *testing.T) {
+	testHome.Conn(t)
+
+	env.API_TOKEN = testToken
+	defer func() { env.API_TOKEN = "" }()
+
+	// like a browser, over plain http. not on localhost as jars and
+	// browsers treat it as secure
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{
+		Jar: jar,
+		Transport: &http.Transport{
+			DialContext: func(
+				ctx context.Context, network string, addr string,
+			) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(
+					ctx, network, testServer.Listener.Addr().String(),
+				)
+			},
+		},
+	}
+	serverURL := "http://mikogo.test"
+
+	res, err := client.Get(serverURL + "/dashboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.Request.URL.Path != "/login" {
+		t.Fatalf("expected redirect to login, got %q", res.Request.URL)
+	}
+
+	res, err = client.PostForm(serverURL+"/login", url.Values{
+		"token": {"wrong"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.Request.URL.Path != "/login" {
+		t.Fatalf("expected back at login, got %q", res.Request.URL)
+	}
+
+	res, err = client.PostForm(serverURL+"/login", url.Values{
+		"token": {testToken},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Request.URL.Path != "/dashboard" || res.StatusCode != http.StatusOK ||
+		!strings.Contains(string(body), "home") {
+		t.Fatalf("unexpected dashboard: %s %d\n%s",
+			res.Request.URL, res.StatusCode, body,
+		)
+	}
+
+	jarURL, err := url.Parse(serverURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := jar.Cookies(jarURL)
+	if len(cookies) != 1 || cookies[0].Value == testToken {
+		t.Fatalf("unexpected cookies: %v", cookies)
+	}
+
+	req, err := http.NewRequest("GET", serverURL+"/dashboard", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.AddCookie(cookies[0])
+
+	res, err = client.Post(serverURL+"/logout", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if loggedIn(req) {
+		t.Fatal("session survived logging out")
+	}
+	if len(jar.Cookies(jarURL)) > 0 {
+		t.Fatal("logging out didnt clear the cookie")
+	}
+}
+
+func TestDashboardCookieSecure(t *testing.T) {
+	env.API_TOKEN = testToken
+	defer func() { env.API_TOKEN = "" }()
+
+	login := func(r *http.Request) *http.Cookie {
+		t.Helper()
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handleLogin(w, r)
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 ||
+			!cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteStrictMode {
+			t.Fatalf("unexpected cookies: %v", cookies)
+		}
+		return cookies[0]
+	}
+	form := "token=" + testToken
+
+	if login(httptest.NewRequest("POST", "/login", strings.NewReader(form))).Secure {
+		t.Fatal("secure cookie over plain http")
+	}
+
+	req := httptest.NewRequest("POST", "https://mikogo/login", strings.NewReader(form))
+	if !login(req).Secure {
+		t.Fatal("expected a secure cookie over https")
+	}
+
+	req = httptest.NewRequest("POST", "/login", strings.NewReader(form))
+	req.Header.Set("X-Forwarded-Proto", "https")
+	if !login(req).Secure {
+		t.Fatal("expected a secure cookie behind a tls proxy")
+	}
+}
+
+func TestDashboardSessionEndsWithToken(t *testing.T) {
+	env.API_TOKEN = testToken
+	defer func() { env.API_TOKEN = "" }()
+
+	id, err := newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/dashboard", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: id})
+	if !loggedIn(req) {
+		t.Fatal("expected to be logged in")
+	}
+
+	env.API_TOKEN = "changed"
+	if loggedIn(req) {
+		t.Fatal("session survived a token change")
+	}
+}
+
+func TestDashboardLoginLimit(t *testing.T) {
+	env.API_TOKEN = testToken
+	defer func() { env.API_TOKEN = "" }()
+	t.Cleanup(func() {
+		loginFailuresMutex.Lock()
+		clear(loginFailures)
+		loginFailuresMutex.Unlock()
+	})
+
+	login := func(token string) *http.Response {
+		t.Helper()
+		res, err := noRedirectClient.PostForm(testServer.URL+"/login", url.Values{
+			"token": {token},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	for range loginMaxFailures {
+		login("wrong")
+	}
+
+	res := login(testToken)
+	if len(res.Cookies()) > 0 ||
+		!strings.Contains(res.Header.Get("Location"), "too+many+attempts") {
+		t.Fatalf("expected login to be refused, got %q", res.Header.Get("Location"))
+	}
+}
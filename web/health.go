@@ -0,0 +1,148 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/env"
+	"github.com/makinori/mikogo/irc"
+)
+
+type serverStatus struct {
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	Address   string `json:"address"`
+	Connected bool   `json:"connected"`
+	// seconds, zero if not connected
+	Uptime float64 `json:"uptime"`
+}
+
+type healthResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		slog.Error("failed to write json", "err", err)
+	}
+}
+
+func writeHealth(w http.ResponseWriter, res healthResponse) {
+	status := http.StatusOK
+	if !res.OK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, res)
+}
+
+// healthz is unauthenticated, so the db write is only done this often
+const writableCheckInterval = time.Second * 5
+
+var (
+	writableCheckedAt time.Time
+	writableErr       error
+	writableMutex     = sync.Mutex{}
+)
+
+func checkWritable() error {
+	writableMutex.Lock()
+	defer writableMutex.Unlock()
+
+	if time.Since(writableCheckedAt) < writableCheckInterval {
+		return writableErr
+	}
+
+	writableErr = db.CheckWritable()
+	writableCheckedAt = time.Now()
+	return writableErr
+}
+
+// process is alive and can write to the db
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	err := checkWritable()
+	if err != nil {
+		writeHealth(w, healthResponse{Error: "db not writable: " + err.Error()})
+		return
+	}
+	writeHealth(w, healthResponse{OK: true})
+}
+
+func serverStatuses() []serverStatus {
+	statuses := []serverStatus{}
+	for _, client := range irc.Clients() {
+		status := serverStatus{
+			Name:      client.Name,
+			State:     client.StateName(),
+			Address:   client.Address(),
+			Connected: client.Connected(),
+		}
+		connectedAt := client.Stats().ConnectedAt
+		if status.Connected && !connectedAt.IsZero() {
+			status.Uptime = time.Since(connectedAt).Seconds()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// home server is connected and registered. unauthenticated, so per
+// server detail is only in the api
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	home := irc.GetClient("home")
+	if home == nil || !home.Connected() {
+		writeHealth(w, healthResponse{Error: "home server not connected"})
+		return
+	}
+	writeHealth(w, healthResponse{OK: true})
+}
+
+func init() {
+	mux.HandleFunc("GET /healthz", handleHealthz)
+	mux.HandleFunc("GET /readyz", handleReadyz)
+}
+
+// for container health checks since the image has no curl.
+// path is either healthz or readyz
+func Healthcheck(path string) error {
+	if env.HTTP_ADDRESS == "" {
+		return errors.New("HTTP_ADDRESS not set")
+	}
+
+	host, port, err := net.SplitHostPort(env.HTTP_ADDRESS)
+	if err != nil {
+		return err
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+
+	client := http.Client{Timeout: time.Second * 5}
+	res, err := client.Get(
+		"http://" + net.JoinHostPort(host, port) + "/" +
+			strings.TrimPrefix(path, "/"),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
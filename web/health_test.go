@@ -0,0 +1,116 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makinori/mikogo/env"
+	"github.com/makinori/mikogo/irc/irctest"
+)
+
+func getHealth(t *testing.T, path string) (int, healthResponse) {
+	t.Helper()
+
+	res, err := http.Get(testServer.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	var health healthResponse
+	err = json.NewDecoder(res.Body).Decode(&health)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res.StatusCode, health
+}
+
+func TestHealthz(t *testing.T) {
+	status, health := getHealth(t, "/healthz")
+	if status != http.StatusOK || !health.OK {
+		t.Fatalf("unexpected health: %d %+v", status, health)
+	}
+}
+
+// polls until readyz returns the status
+func waitForReadyz(t *testing.T, status int) healthResponse {
+	t.Helper()
+	deadline := time.Now().Add(irctest.TIMEOUT)
+	for {
+		got, health := getHealth(t, "/readyz")
+		if got == status {
+			return health
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected readyz %d, got %d %+v", status, got, health)
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	conn := testHome.Conn(t)
+
+	waitForReadyz(t, http.StatusOK)
+
+	// not ready whilst reconnecting
+	conn.Close()
+	health := waitForReadyz(t, http.StatusServiceUnavailable)
+	if health.OK || health.Error == "" {
+		t.Fatalf("unexpected health: %+v", health)
+	}
+
+	testHome.Reconnect(t)
+	waitForReadyz(t, http.StatusOK)
+}
+
+func TestReadyzHidesServers(t *testing.T) {
+	testHome.Conn(t)
+	waitForReadyz(t, http.StatusOK)
+
+	res, err := http.Get(testServer.URL + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), testHome.Addr) {
+		t.Fatalf("readyz shows server addresses: %s", body)
+	}
+
+	env.API_TOKEN = testToken
+	defer func() { env.API_TOKEN = "" }()
+
+	status, _ := apiRequest(t, "GET", "/api/status", "", nil)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized, got %d", status)
+	}
+
+	status, body = apiRequest(t, "GET", "/api/status", testToken, nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected ok, got %d", status)
+	}
+
+	var servers []serverStatus
+	err = json.Unmarshal(body, &servers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.IndexFunc(servers, func(server serverStatus) bool {
+		return server.Name == "home"
+	})
+	if i == -1 || servers[i].Address != testHome.Addr ||
+		servers[i].State != "connected" {
+		t.Fatalf("unexpected servers: %+v", servers)
+	}
+}
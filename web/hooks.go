@@ -0,0 +1,144 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/makinori/mikogo/db"
+	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/webhook"
+)
+
+const hookMaxBody = 1 << 20
+
+func validHMAC(secret string, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+func validSecret(secret string, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+// github and gitea sign the body, gitlab sends the secret as is
+// and anything else can use a bearer token
+func verifyHook(r *http.Request, secret string, body []byte) bool {
+	if secret == "" {
+		return false
+	}
+
+	signature, ok := strings.CutPrefix(
+		r.Header.Get("X-Hub-Signature-256"), "sha256=",
+	)
+	if ok {
+		return validHMAC(secret, body, signature)
+	}
+
+	signature = r.Header.Get("X-Gitea-Signature")
+	if signature != "" {
+		return validHMAC(secret, body, signature)
+	}
+
+	token := r.Header.Get("X-Gitlab-Token")
+	if token != "" {
+		return validSecret(secret, token)
+	}
+
+	token, ok = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if ok {
+		return validSecret(secret, token)
+	}
+
+	return false
+}
+
+func hookEvent(r *http.Request) string {
+	for _, header := range []string{
+		"X-GitHub-Event", "X-Gitea-Event", "X-Gitlab-Event",
+	} {
+		event := r.Header.Get(header)
+		if event != "" {
+			return event
+		}
+	}
+	return ""
+}
+
+func handleHook(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	hook, err, exists := db.Hooks.Get(name)
+	if err != nil {
+		slog.Error("failed to get hook", "name", name, "err", err)
+		writeJSON(w, http.StatusInternalServerError, apiError{
+			Error: "failed to get hook",
+		})
+		return
+	}
+	if !exists {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "hook not found"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, hookMaxBody))
+	if err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, apiError{
+			Error: "body too large",
+		})
+		return
+	}
+
+	if !verifyHook(r, hook.Secret, body) {
+		slog.Warn("hook failed verification", "name", name, "addr", r.RemoteAddr)
+		writeJSON(w, http.StatusUnauthorized, apiError{Error: "unauthorized"})
+		return
+	}
+
+	lines, err := webhook.Format(hook.Template, hookEvent(r), body)
+	if errors.Is(err, webhook.ErrUnknownTemplate) {
+		writeJSON(w, http.StatusInternalServerError, apiError{
+			Error: "hook has unknown template: " + hook.Template,
+		})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{
+			Error: "invalid payload: " + err.Error(),
+		})
+		return
+	}
+
+	if len(lines) == 0 {
+		writeJSON(w, http.StatusOK, ok{OK: true})
+		return
+	}
+
+	client := irc.GetClient(hook.Server)
+	if client == nil {
+		writeJSON(w, http.StatusServiceUnavailable, apiError{
+			Error: "server not found: " + hook.Server,
+		})
+		return
+	}
+
+	// queued in the outbox if disconnected
+	client.Send(hook.Channel, strings.Join(lines, "\n"))
+
+	writeJSON(w, http.StatusAccepted, ok{OK: true})
+}
+
+func init() {
+	mux.HandleFunc("POST /hooks/{name}", handleHook)
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/makinori/mikogo/db"
+)
+
+func postHook(
+	t *testing.T, name string, body string, headers map[string]string,
+) int {
+	t.Helper()
+
+	req, err := http.NewRequest(
+		"POST", testServer.URL+"/hooks/"+name, bytes.NewReader([]byte(body)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	return res.StatusCode
+}
+
+func TestHook(t *testing.T) {
+	conn := testHome.Conn(t)
+
+	err := db.Hooks.Put("builds", db.Hook{
+		Server:   "home",
+		Channel:  "#builds",
+		Secret:   "secret",
+		Template: "generic",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Hooks.Delete("builds") })
+
+	body := `{"text":"build passed"}`
+
+	status := postHook(t, "builds", body, nil)
+	if status != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized without secret, got %d", status)
+	}
+
+	status = postHook(t, "builds", body, map[string]string{
+		"X-Hub-Signature-256": "sha256=00",
+	})
+	if status != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized with bad signature, got %d", status)
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	status = postHook(t, "builds", body, map[string]string{
+		"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(mac.Sum(nil)),
+	})
+	if status != http.StatusAccepted {
+		t.Fatalf("expected accepted with signature, got %d", status)
+	}
+	conn.Expect(t, `PRIVMSG #builds :build passed$`)
+
+	status = postHook(t, "builds", `{"text":"deployed"}`, map[string]string{
+		"Authorization": "Bearer secret",
+	})
+	if status != http.StatusAccepted {
+		t.Fatalf("expected accepted with token, got %d", status)
+	}
+	conn.Expect(t, `PRIVMSG #builds :deployed$`)
+
+	status = postHook(t, "nope", body, nil)
+	if status != http.StatusNotFound {
+		t.Fatalf("expected not found, got %d", status)
+	}
+}
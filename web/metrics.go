@@ -0,0 +1,16 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/makinori/mikogo/metrics"
+)
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.WriteAll(w)
+}
+
+func init() {
+	mux.HandleFunc("GET /metrics", handleMetrics)
+}
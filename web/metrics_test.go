@@ -0,0 +1,36 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/makinori/mikogo/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics.NewCounter("web_test_total", "a counter").Inc()
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected content type: %s", res.Header.Get("Content-Type"))
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "\nweb_test_total 1\n") {
+		t.Fatalf("counter missing:\n%s", body)
+	}
+}
@@ -0,0 +1,42 @@
+// optional http server for monitoring the bot
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/makinori/mikogo/env"
+)
+
+var mux = http.NewServeMux()
+
+// does nothing if HTTP_ADDRESS isnt set
+func Start() error {
+	if env.HTTP_ADDRESS == "" {
+		return nil
+	}
+
+	// listen first so a taken port fails at startup
+	listener, err := net.Listen("tcp", env.HTTP_ADDRESS)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: time.Second * 10,
+	}
+
+	go func() {
+		slog.Info("http listening", "addr", listener.Addr().String())
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("http server stopped", "err", err)
+		}
+	}()
+
+	return nil
+}
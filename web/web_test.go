@@ -0,0 +1,34 @@
+package web
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/makinori/mikogo/irc"
+	"github.com/makinori/mikogo/irc/irctest"
+)
+
+var (
+	testHome   *irctest.Home
+	testServer *httptest.Server
+)
+
+func TestMain(m *testing.M) {
+	irc.RECONNECT_DURATION = time.Millisecond * 100
+
+	var err error
+	testHome, err = irctest.StartHome("mikogo-web", irc.Sync)
+	if err != nil {
+		panic(err)
+	}
+
+	testServer = httptest.NewServer(mux)
+
+	code := m.Run()
+
+	testServer.Close()
+	testHome.Close()
+	os.Exit(code)
+}
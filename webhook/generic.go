@@ -0,0 +1,36 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// {"text": "line\nanother line"}
+func formatGeneric(event string, body []byte) ([]string, error) {
+	var payload struct {
+		Text string `json:"text"`
+	}
+
+	err := json.Unmarshal(body, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for line := range strings.SplitSeq(payload.Text, "\n") {
+		line = clean(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("missing text")
+	}
+
+	if len(lines) > MAX_LINES {
+		lines = append(lines[:MAX_LINES], more(len(lines)-MAX_LINES))
+	}
+
+	return lines, nil
+}
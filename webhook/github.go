@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// gitea sends the same shapes, with compare_url instead of compare
+
+type githubPush struct {
+	Ref        string `json:"ref"`
+	Compare    string `json:"compare"`
+	CompareURL string `json:"compare_url"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Pusher struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+	} `json:"pusher"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+	Commits []struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+	} `json:"commits"`
+	TotalCommits int `json:"total_commits"`
+}
+
+type githubPullRequest struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Title   string `json:"title"`
+		HTMLURL string `json:"html_url"`
+		Merged  bool   `json:"merged"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+func formatGitHubPush(body []byte) ([]string, error) {
+	var payload githubPush
+	err := json.Unmarshal(body, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	user := payload.Pusher.Name
+	if user == "" {
+		user = payload.Pusher.Username
+	}
+	if user == "" {
+		user = payload.Sender.Login
+	}
+
+	url := payload.Compare
+	if url == "" {
+		url = payload.CompareURL
+	}
+
+	// github doesnt send a total and caps commits at 20
+	total := max(payload.TotalCommits, len(payload.Commits))
+
+	commits := make([]commit, len(payload.Commits))
+	for i, c := range payload.Commits {
+		commits[i] = commit{id: c.ID, message: c.Message}
+	}
+
+	branch, _ := strings.CutPrefix(payload.Ref, "refs/heads/")
+
+	return formatPush(
+		payload.Repository.FullName, user, branch, url, commits, total,
+	), nil
+}
+
+func formatGitHubPullRequest(body []byte) ([]string, error) {
+	var payload githubPullRequest
+	err := json.Unmarshal(body, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	action := payload.Action
+	switch action {
+	case "opened", "reopened", "closed":
+	default:
+		// edits, labels, reviews and such are too noisy
+		return nil, nil
+	}
+	if action == "closed" && payload.PullRequest.Merged {
+		action = "merged"
+	}
+
+	return formatPullRequest(
+		payload.Repository.FullName, payload.Sender.Login, action,
+		"pull request", payload.Number,
+		payload.PullRequest.Title, payload.PullRequest.HTMLURL,
+	), nil
+}
+
+// https://docs.github.com/en/webhooks/webhook-events-and-payloads
+func formatGitHub(event string, body []byte) ([]string, error) {
+	switch event {
+	case "push":
+		return formatGitHubPush(body)
+	case "pull_request":
+		return formatGitHubPullRequest(body)
+	}
+	// includes ping
+	return nil, nil
+}
+
+// https://docs.gitea.com/usage/webhooks
+func formatGitea(event string, body []byte) ([]string, error) {
+	return formatGitHub(event, body)
+}
@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type gitlabProject struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	WebURL            string `json:"web_url"`
+}
+
+type gitlabPush struct {
+	Ref               string        `json:"ref"`
+	Before            string        `json:"before"`
+	After             string        `json:"after"`
+	UserName          string        `json:"user_name"`
+	UserUsername      string        `json:"user_username"`
+	Project           gitlabProject `json:"project"`
+	TotalCommitsCount int           `json:"total_commits_count"`
+	Commits           []struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+	} `json:"commits"`
+}
+
+type gitlabMergeRequest struct {
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Project          gitlabProject `json:"project"`
+	ObjectAttributes struct {
+		IID    int    `json:"iid"`
+		Title  string `json:"title"`
+		URL    string `json:"url"`
+		Action string `json:"action"`
+	} `json:"object_attributes"`
+}
+
+func formatGitLabPush(body []byte) ([]string, error) {
+	var payload gitlabPush
+	err := json.Unmarshal(body, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	user := payload.UserUsername
+	if user == "" {
+		user = payload.UserName
+	}
+
+	commits := make([]commit, len(payload.Commits))
+	for i, c := range payload.Commits {
+		commits[i] = commit{id: c.ID, message: c.Message}
+	}
+
+	url := payload.Project.WebURL + "/-/compare/" +
+		payload.Before + "..." + payload.After
+
+	branch, _ := strings.CutPrefix(payload.Ref, "refs/heads/")
+
+	return formatPush(
+		payload.Project.PathWithNamespace, user, branch, url,
+		commits, max(payload.TotalCommitsCount, len(payload.Commits)),
+	), nil
+}
+
+func formatGitLabMergeRequest(body []byte) ([]string, error) {
+	var payload gitlabMergeRequest
+	err := json.Unmarshal(body, &payload)
+	if err != nil {
+		return nil, err
+	}
+
+	action := payload.ObjectAttributes.Action
+	switch action {
+	case "open":
+		action = "opened"
+	case "reopen":
+		action = "reopened"
+	case "close":
+		action = "closed"
+	case "merge":
+		action = "merged"
+	default:
+		return nil, nil
+	}
+
+	return formatPullRequest(
+		payload.Project.PathWithNamespace, payload.User.Username, action,
+		"merge request", payload.ObjectAttributes.IID,
+		payload.ObjectAttributes.Title, payload.ObjectAttributes.URL,
+	), nil
+}
+
+// https://docs.gitlab.com/user/project/integrations/webhook_events/
+func formatGitLab(event string, body []byte) ([]string, error) {
+	switch event {
+	case "Push Hook":
+		return formatGitLabPush(body)
+	case "Merge Request Hook":
+		return formatGitLabMergeRequest(body)
+	}
+	return nil, nil
+}
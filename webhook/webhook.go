@@ -0,0 +1,139 @@
+// turns webhook payloads into irc messages
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/makinori/mikogo/ircf"
+)
+
+const (
+	// how many commits or lines to show before "and n more"
+	MAX_LINES = 4
+	// payloads are from outside, keep each field and line short enough
+	// to fit in one privmsg
+	MAX_FIELD_LENGTH = 200
+	MAX_LINE_LENGTH  = 400
+)
+
+// event is from the forge's event header and might be empty.
+// no lines means nothing worth announcing
+type Template func(event string, body []byte) ([]string, error)
+
+var Templates = map[string]Template{
+	"generic": formatGeneric,
+	"github":  formatGitHub,
+	"gitea":   formatGitea,
+	"gitlab":  formatGitLab,
+}
+
+func TemplateNames() []string {
+	return slices.Sorted(maps.Keys(Templates))
+}
+
+var ErrUnknownTemplate = errors.New("unknown template")
+
+func Format(template string, event string, body []byte) ([]string, error) {
+	format, ok := Templates[template]
+	if !ok {
+		return nil, ErrUnknownTemplate
+	}
+
+	lines, err := format(event, body)
+	for i, line := range lines {
+		lines[i] = truncate(line, MAX_LINE_LENGTH)
+	}
+	return lines, err
+}
+
+// strips control characters and newlines from a payload field
+func clean(field string) string {
+	return ircf.Sanitize(field, MAX_FIELD_LENGTH)
+}
+
+// formatting is ours by now so only cut it
+func truncate(line string, max int) string {
+	runes := []rune(line)
+	if len(runes) <= max {
+		return line
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// first line of a commit message
+func summary(message string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return clean(line)
+}
+
+func more(n int) string {
+	return ircf.Color(98).Format(fmt.Sprintf("and %d more", n))
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+var (
+	formatRepo   = ircf.BoldWhite
+	formatBranch = ircf.Color(43)
+	formatUser   = ircf.Bold()
+	formatHash   = ircf.Color(98)
+)
+
+type commit struct {
+	id      string
+	message string
+}
+
+// shared by forges
+func formatPush(
+	repo string, user string, branch string, url string,
+	commits []commit, total int,
+) []string {
+	if total == 0 {
+		return nil
+	}
+
+	lines := []string{fmt.Sprintf("[%s] %s pushed %s to %s %s",
+		formatRepo.Format(clean(repo)),
+		formatUser.Format(clean(user)),
+		plural(total, "commit"),
+		formatBranch.Format(clean(branch)),
+		clean(url),
+	)}
+
+	for i, c := range commits {
+		if i == MAX_LINES {
+			lines = append(lines, more(total-MAX_LINES))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  %s %s",
+			formatHash.Format(clean(c.id[:min(len(c.id), 7)])), summary(c.message),
+		))
+	}
+
+	return lines
+}
+
+func formatPullRequest(
+	repo string, user string, action string, kind string,
+	number int, title string, url string,
+) []string {
+	return []string{fmt.Sprintf("[%s] %s %s %s #%d: %s %s",
+		formatRepo.Format(clean(repo)),
+		formatUser.Format(clean(user)),
+		clean(action),
+		kind,
+		number,
+		ircf.Bold().Format(clean(title)),
+		clean(url),
+	)}
+}
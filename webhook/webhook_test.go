@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/makinori/mikogo/ircf"
+)
+
+func formatStripped(
+	t *testing.T, template string, event string, body string,
+) []string {
+	t.Helper()
+	lines, err := Format(template, event, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range lines {
+		lines[i] = ircf.Strip(lines[i])
+	}
+	return lines
+}
+
+func expectLines(t *testing.T, got []string, expected ...string) {
+	t.Helper()
+	if !slices.Equal(got, expected) {
+		t.Fatalf("expected:\n%q\ngot:\n%q", expected, got)
+	}
+}
+
+func TestGeneric(t *testing.T) {
+	expectLines(t,
+		formatStripped(t, "generic", "", `{"text":"deployed\n\nv1.2"}`),
+		"deployed", "v1.2",
+	)
+
+	_, err := Format("generic", "", []byte(`{}`))
+	if err == nil {
+		t.Fatal("expected error for missing text")
+	}
+
+	_, err = Format("nope", "", []byte(`{}`))
+	if err != ErrUnknownTemplate {
+		t.Fatalf("expected unknown template, got %v", err)
+	}
+}
+
+func TestGitHub(t *testing.T) {
+	expectLines(t,
+		formatStripped(t, "github", "push", `{
+			"ref": "refs/heads/main",
+			"compare": "https://github.com/maki/mikogo/compare/a...b",
+			"repository": {"full_name": "maki/mikogo"},
+			"pusher": {"name": "maki"},
+			"commits": [
+				{"id": "0123456789abcdef", "message": "fix things\n\nlong body"},
+				{"id": "fedcba9876543210", "message": "more things"}
+			]
+		}`),
+		"[maki/mikogo] maki pushed 2 commits to main "+
+			"https://github.com/maki/mikogo/compare/a...b",
+		"  0123456 fix things",
+		"  fedcba9 more things",
+	)
+
+	expectLines(t,
+		formatStripped(t, "github", "pull_request", `{
+			"action": "closed",
+			"number": 5,
+			"pull_request": {
+				"title": "add hooks",
+				"html_url": "https://github.com/maki/mikogo/pull/5",
+				"merged": true
+			},
+			"repository": {"full_name": "maki/mikogo"},
+			"sender": {"login": "alice"}
+		}`),
+		"[maki/mikogo] alice merged pull request #5: add hooks "+
+			"https://github.com/maki/mikogo/pull/5",
+	)
+
+	expectLines(t, formatStripped(t, "github", "ping", `{}`))
+}
+
+func TestGitLab(t *testing.T) {
+	expectLines(t,
+		formatStripped(t, "gitlab", "Merge Request Hook", `{
+			"user": {"username": "alice"},
+			"project": {"path_with_namespace": "maki/mikogo"},
+			"object_attributes": {
+				"iid": 3, "title": "add hooks", "action": "open",
+				"url": "https://gitlab.com/maki/mikogo/-/merge_requests/3"
+			}
+		}`),
+		"[maki/mikogo] alice opened merge request #3: add hooks "+
+			"https://gitlab.com/maki/mikogo/-/merge_requests/3",
+	)
+}
+
+func TestSanitizesPayload(t *testing.T) {
+	expectLines(t,
+		formatStripped(t, "github", "pull_request", `{
+			"action": "opened",
+			"number": 7,
+			"pull_request": {
+				"title": "fix\r\nPRIVMSG #chan :hi\u0002",
+				"html_url": "https://github.com/maki/mikogo/pull/7"
+			},
+			"repository": {"full_name": "maki/mikogo"},
+			"sender": {"login": "ma\nki"}
+		}`),
+		"[maki/mikogo] ma ki opened pull request #7: fix PRIVMSG #chan :hi "+
+			"https://github.com/maki/mikogo/pull/7",
+	)
+
+	expectLines(t,
+		formatStripped(t, "generic", "", `{"text":"one\r\n\u0001two"}`),
+		"one", "two",
+	)
+
+	lines, err := Format("generic", "", []byte(
+		`{"text":"`+strings.Repeat("a", MAX_LINE_LENGTH*2)+`"}`,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len([]rune(lines[0])) > MAX_LINE_LENGTH {
+		t.Fatalf("line too long: %d", len([]rune(lines[0])))
+	}
+}